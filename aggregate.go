@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// aggregate returns the smallest set of networks covering exactly the same
+// addresses as nets, sorted with IPv4 before IPv6.
+func aggregate(nets []*net.IPNet) []*net.IPNet {
+	out := []*net.IPNet{}
+	for _, n := range disjoint(nets) {
+		out = append(out, n)
+		for len(out) >= 2 {
+			parent, ok := siblingsParent(out[len(out)-2], out[len(out)-1])
+			if !ok {
+				break
+			}
+			out = append(out[:len(out)-2], parent)
+		}
+	}
+	return out
+}
+
+// hierarchy writes nets sorted, each indented under the most specific other
+// listed network containing it.
+func hierarchy(w io.Writer, nets []*net.IPNet) {
+	sorted := dedupe(nets)
+	sort.Slice(sorted, func(i, j int) bool { return netLess(sorted[i], sorted[j]) })
+	parents := []*net.IPNet{}
+	for _, n := range sorted {
+		for len(parents) > 0 && !netContains(parents[len(parents)-1], n) {
+			parents = parents[:len(parents)-1]
+		}
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", len(parents)), netString(n))
+		parents = append(parents, n)
+	}
+}
+
+// capPrefixLen splits each of nets shorter than /maxLen into its /maxLen
+// subnets, refusing to return more than maxOutput networks.
+func capPrefixLen(nets []*net.IPNet, maxLen int, maxOutput int) ([]*net.IPNet, error) {
+	total := new(big.Int)
+	for _, n := range nets {
+		ones, bits := n.Mask.Size()
+		if maxLen > bits {
+			return nil, fmt.Errorf("--max-len %d is longer than /%d", maxLen, bits)
+		}
+		if ones < maxLen {
+			count, _ := subnetCount(n, maxLen)
+			total.Add(total, count)
+		} else {
+			total.Add(total, big.NewInt(1))
+		}
+	}
+	if err := checkOutputLimit(total, maxOutput); err != nil {
+		return nil, err
+	}
+	max := math.MaxInt32
+	if total.IsInt64() && total.Int64() < int64(max) {
+		max = int(total.Int64())
+	}
+	out := []*net.IPNet{}
+	for _, n := range nets {
+		if ones, _ := n.Mask.Size(); ones < maxLen {
+			out = append(out, subnets(n, maxLen, max)...)
+		} else {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// disjoint returns nets sorted and without any network covered by another.
+// Prefixes either nest or don't overlap at all, so the result never
+// overlaps.
+func disjoint(nets []*net.IPNet) []*net.IPNet {
+	sorted := make([]*net.IPNet, len(nets))
+	for i, n := range nets {
+		sorted[i] = &net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return netLess(sorted[i], sorted[j]) })
+
+	out := []*net.IPNet{}
+	for _, n := range sorted {
+		if len(out) == 0 || !netContains(out[len(out)-1], n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// addressCount returns the total number of IPs in nets.
+func addressCount(nets []*net.IPNet) *big.Int {
+	total := new(big.Int)
+	for _, n := range nets {
+		ones, bits := n.Mask.Size()
+		total.Add(total, new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)))
+	}
+	return total
+}
+
+// checkAggregate checks out covers exactly the addresses in does: each of in
+// is within one of out, none of out overlap, and they add up to the same
+// number of addresses, so out can't cover anything extra.
+func checkAggregate(in, out []*net.IPNet) error {
+	sorted := disjoint(out)
+	if len(sorted) != len(out) {
+		return fmt.Errorf("aggregation has overlapping prefixes")
+	}
+	for _, n := range in {
+		n = &net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}
+		// The last of sorted not after n is the only one which could hold it.
+		i := sort.Search(len(sorted), func(i int) bool { return netLess(n, sorted[i]) })
+		if i == 0 || !netContains(sorted[i-1], n) {
+			return fmt.Errorf("aggregation doesn't cover %s", n)
+		}
+	}
+	before := addressCount(disjoint(in))
+	after := addressCount(out)
+	if before.Cmp(after) != 0 {
+		return fmt.Errorf("aggregation covers %d addresses but the input covers %d", after, before)
+	}
+	return nil
+}
+
+// aggregationSavings summarizes how many route table entries aggregating
+// nets saves.
+func aggregationSavings(nets []*net.IPNet) string {
+	aggregated := len(aggregate(nets))
+	return fmt.Sprintf("%d prefixes aggregate to %d, saved %d", len(nets), aggregated, len(nets)-aggregated)
+}
+
+// aggregateStats summarizes aggregating in to out, checking it with
+// checkAggregate.
+func aggregateStats(in, out []*net.IPNet) (string, error) {
+	if err := checkAggregate(in, out); err != nil {
+		return "", err
+	}
+	after := addressCount(out)
+	fewer := 0
+	if len(in) > 0 {
+		fewer = (len(in) - len(out)) * 100 / len(in)
+	}
+	return fmt.Sprintf("reduced %d prefixes to %d (%d%% fewer), covering the same %s addresses",
+		len(in), len(out), fewer, groupDigits(after.String(), ",")), nil
+}
+
+// mergeAdjacent makes a single pass over nets merging each pair of sibling
+// prefixes into their parent. Unlike aggregate, nothing else changes: merged
+// parents aren't merged again, and prefixes nested within others are kept.
+func mergeAdjacent(nets []*net.IPNet) []*net.IPNet {
+	sorted := make([]*net.IPNet, len(nets))
+	listed := map[string]int{}
+	for i, n := range nets {
+		sorted[i] = &net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return netLess(sorted[i], sorted[j]) })
+	for _, n := range sorted {
+		listed[n.String()]++
+	}
+
+	out := []*net.IPNet{}
+	for _, n := range sorted {
+		if listed[n.String()] == 0 {
+			continue // merged into its parent already
+		}
+		if sibling, ok := upperSibling(n); ok && listed[sibling.String()] > 0 {
+			parent, _ := siblingsParent(n, sibling)
+			listed[n.String()]--
+			listed[sibling.String()]--
+			out = append(out, parent)
+			continue
+		}
+		listed[n.String()]--
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool { return netLess(out[i], out[j]) })
+	return out
+}
+
+// upperSibling returns the other half of n's parent when n is the lower half.
+func upperSibling(n *net.IPNet) (*net.IPNet, bool) {
+	ones, _ := n.Mask.Size()
+	if ones == 0 {
+		return nil, false
+	}
+	byteIdx, bit := (ones-1)/8, byte(0x80>>uint((ones-1)%8))
+	if n.IP[byteIdx]&bit != 0 {
+		return nil, false
+	}
+	ip := make(net.IP, len(n.IP))
+	copy(ip, n.IP)
+	ip[byteIdx] |= bit
+	return &net.IPNet{IP: ip, Mask: n.Mask}, true
+}
+
+// exclude returns the networks covering parent except for child, in order.
+// It halves parent repeatedly, keeping each half that doesn't hold child.
+func exclude(parent, child *net.IPNet) []*net.IPNet {
+	if !netContains(parent, child) {
+		return []*net.IPNet{parent}
+	}
+	childOnes, bits := child.Mask.Size()
+	out := []*net.IPNet{}
+	n := &net.IPNet{IP: parent.IP.Mask(parent.Mask), Mask: parent.Mask}
+	for ones, _ := n.Mask.Size(); ones < childOnes; ones++ {
+		mask := net.CIDRMask(ones+1, bits)
+		lower := &net.IPNet{IP: n.IP, Mask: mask}
+		upper, _ := upperSibling(lower)
+		if lower.Contains(child.IP) {
+			out = append(out, upper)
+			n = lower
+		} else {
+			out = append(out, lower)
+			n = upper
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return netLess(out[i], out[j]) })
+	return out
+}
+
+// supernet returns the smallest single network containing all of nets,
+// which must be the same IP version.
+func supernet(nets []*net.IPNet) (*net.IPNet, error) {
+	if len(nets) == 0 {
+		return nil, fmt.Errorf("no CIDRs given")
+	}
+	ref := nets[0].IP.Mask(nets[0].Mask)
+	ones := len(ref) * 8
+	for _, n := range nets {
+		if len(n.IP) != len(ref) {
+			return nil, fmt.Errorf("can't cover both IPv4 and IPv6 with one CIDR")
+		}
+		first := n.IP.Mask(n.Mask)
+		for _, ip := range []net.IP{first, maxIP(&net.IPNet{IP: first, Mask: n.Mask})} {
+			if l := commonPrefixLen(ref, ip); l < ones {
+				ones = l
+			}
+		}
+	}
+	mask := net.CIDRMask(ones, len(ref)*8)
+	return &net.IPNet{IP: ref.Mask(mask), Mask: mask}, nil
+}
+
+// commonPrefixLen returns how many leading bits a and b have in common.
+func commonPrefixLen(a, b net.IP) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := i * 8
+			for ; x&0x80 == 0; x <<= 1 {
+				n++
+			}
+			return n
+		}
+	}
+	return len(a) * 8
+}
+
+// netLess orders IPv4 before IPv6, then by address, then larger networks first.
+func netLess(a, b *net.IPNet) bool {
+	if len(a.IP) != len(b.IP) {
+		return len(a.IP) < len(b.IP)
+	}
+	if c := bytes.Compare(a.IP, b.IP); c != 0 {
+		return c < 0
+	}
+	aOnes, _ := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	return aOnes < bOnes
+}
+
+// netContains reports whether every address in b is also in a.
+func netContains(a, b *net.IPNet) bool {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	return aBits == bBits && aOnes <= bOnes && a.Contains(b.IP)
+}
+
+func netsOverlap(a, b *net.IPNet) bool {
+	return netContains(a, b) || netContains(b, a)
+}
+
+// siblingsParent returns the network formed by a and b when they are the two
+// halves of it.
+func siblingsParent(a, b *net.IPNet) (*net.IPNet, bool) {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	if aBits != bBits || aOnes != bOnes || aOnes == 0 || a.IP.Equal(b.IP) {
+		return nil, false
+	}
+	mask := net.CIDRMask(aOnes-1, aBits)
+	parent := a.IP.Mask(mask)
+	if !parent.Equal(b.IP.Mask(mask)) {
+		return nil, false
+	}
+	return &net.IPNet{IP: parent, Mask: mask}, true
+}
+
+func aggregateMain(o options, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if o.sortedInput {
+		return sortedAggregateMain(o, args, stdin, stdout, stderr)
+	}
+	return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+		nets, err := listNets(args, in)
+		if err != nil {
+			return err
+		}
+		aggregated := aggregate(nets)
+		if o.maxLen > 0 {
+			if aggregated, err = capPrefixLen(aggregated, o.maxLen, o.maxOutput); err != nil {
+				return err
+			}
+		}
+		if o.aggregateStrict {
+			if err := checkAggregate(nets, aggregated); err != nil {
+				return fmt.Errorf("--aggregate-strict: %v", err)
+			}
+		}
+		if o.aggregateStats {
+			stats, err := aggregateStats(nets, aggregated)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(stderr, stats)
+		}
+		for _, n := range aggregated {
+			fmt.Fprintln(out, netString(n))
+		}
+		return nil
+	})
+}
+
+// sortedAggregator aggregates networks given in sorted order, writing each
+// aggregated network as soon as nothing later could merge with it. Only the
+// pending run is kept, each network of which lies in the upper sibling of the
+// one before, so there is at most one per prefix length.
+type sortedAggregator struct {
+	w       io.Writer
+	last    *net.IPNet
+	pending []*net.IPNet
+}
+
+func (a *sortedAggregator) add(n *net.IPNet) error {
+	n = &net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}
+	if a.last != nil && netLess(n, a.last) {
+		return fmt.Errorf("%s comes before %s, so the input isn't sorted", n, a.last)
+	}
+	a.last = n
+	if len(a.pending) > 0 && netContains(a.pending[len(a.pending)-1], n) {
+		return nil
+	}
+	a.pending = append(a.pending, n)
+	for len(a.pending) >= 2 {
+		parent, ok := siblingsParent(a.pending[len(a.pending)-2], a.pending[len(a.pending)-1])
+		if !ok {
+			break
+		}
+		a.pending = append(a.pending[:len(a.pending)-2], parent)
+	}
+	if len(a.pending) >= 2 {
+		top := a.pending[len(a.pending)-1]
+		if upper, ok := upperSibling(a.pending[len(a.pending)-2]); !ok || !netContains(upper, top) {
+			a.flush(len(a.pending) - 1)
+		}
+	}
+	return nil
+}
+
+// flush writes the first n pending networks.
+func (a *sortedAggregator) flush(n int) {
+	for _, p := range a.pending[:n] {
+		fmt.Fprintln(a.w, netString(p))
+	}
+	a.pending = a.pending[n:]
+}
+
+// aggregateSorted aggregates the sorted list read from r to w in one pass.
+func aggregateSorted(r io.Reader, w io.Writer) error {
+	a := &sortedAggregator{w: w}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		e := listEntry{line: line, cidr: listText(scanner.Text())}
+		if e.cidr == "" {
+			continue
+		}
+		_, n, err := parseCIDR(e.cidr)
+		if err == nil {
+			err = a.add(n)
+		}
+		if err != nil {
+			return e.err(err)
+		}
+	}
+	a.flush(len(a.pending))
+	return scanner.Err()
+}
+
+// sortedAggregateMain is aggregateMain for --sorted-input. Unlike listMain,
+// output is written as it's produced rather than buffered, so --out had
+// better not be the --in file.
+func sortedAggregateMain(o options, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if o.aggregateStats || o.aggregateStrict || o.maxLen > 0 {
+		fmt.Fprintln(stderr, "--aggregate-stats, --aggregate-strict and --max-len can't be used with --sorted-input")
+		return exitFailure
+	}
+	in := io.NopCloser(strings.NewReader(strings.Join(args, "\n")))
+	if len(args) == 0 {
+		var err error
+		if in, err = openInput(o.in, stdin); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitInputFile
+		}
+	}
+	defer in.Close()
+	out, err := createOutput(o.out, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitOutputFile
+	}
+	w := bufio.NewWriter(out)
+	err = aggregateSorted(onlyVersion(in, o.only), w)
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	return 0
+}
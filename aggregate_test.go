@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		in       []string
+		expected []string
+	}{
+		{[]string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{[]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/24", "10.0.3.0/24"}, []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32"}, []string{"10.0.0.0/8"}},
+		{[]string{"10.0.0.128/25", "10.0.1.0/25"}, []string{"10.0.0.128/25", "10.0.1.0/25"}},
+		{[]string{"10.0.0.5/24", "10.0.0.0/24"}, []string{"10.0.0.0/24"}},
+		{[]string{"2001:db8:1::/48", "10.0.0.0/24", "2001:db8::/48"}, []string{"10.0.0.0/24", "2001:db8::/47"}},
+	}
+	for _, tt := range tests {
+		nets := []*net.IPNet{}
+		for _, cidr := range tt.in {
+			_, n, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nets = append(nets, n)
+		}
+		got := []string{}
+		for _, n := range aggregate(nets) {
+			got = append(got, n.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("%v:\ngot      %v\nexpected %v", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestAggregateMainFiles(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "list.txt")
+	out := filepath.Join(dir, "aggregated.txt")
+	list := "# office\n10.0.1.0/24\n10.0.0.0/24\n\n192.168.0.0/24  # lab\n"
+	if err := os.WriteFile(in, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := aggregateMain(options{in: in, out: out}, nil, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "10.0.0.0/23\n192.168.0.0/24\n"; string(b) != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", b, expected)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+
+	code = aggregateMain(options{in: filepath.Join(dir, "missing.txt")}, nil, nil, &stdout, &stderr)
+	if code != exitInputFile {
+		t.Errorf("expected exit %d for missing input, got %d", exitInputFile, code)
+	}
+	code = aggregateMain(options{in: in, out: filepath.Join(dir, "missing", "out.txt")}, nil, nil, &stdout, &stderr)
+	if code != exitOutputFile {
+		t.Errorf("expected exit %d for uncreatable output, got %d", exitOutputFile, code)
+	}
+}
+
+func TestAggregateStats(t *testing.T) {
+	in := []*net.IPNet{}
+	for i := 0; i < 50; i++ {
+		in = append(in, &net.IPNet{IP: net.IPv4(10, 0, byte(i/25), byte(i%25*8)).To4(), Mask: net.CIDRMask(29, 32)})
+	}
+	in = append(in, &net.IPNet{IP: net.IPv4(10, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)}) // already covered
+	out := aggregate(in)
+
+	stats, err := aggregateStats(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "reduced 51 prefixes to 6 (88% fewer), covering the same 400 addresses"
+	if stats != expected {
+		t.Errorf("\ngot      %s\nexpected %s", stats, expected)
+	}
+
+	if _, err := aggregateStats(in, out[1:]); err == nil {
+		t.Error("expected differing address totals to be an error")
+	}
+}
+
+func TestCheckAggregate(t *testing.T) {
+	parse := func(cidrs ...string) []*net.IPNet {
+		nets := []*net.IPNet{}
+		for _, cidr := range cidrs {
+			_, n, _ := net.ParseCIDR(cidr)
+			nets = append(nets, n)
+		}
+		return nets
+	}
+	in := parse("10.0.0.0/25", "10.0.0.128/25", "10.0.2.0/24", "10.0.2.5/32", "2001:db8::/48")
+	if err := checkAggregate(in, aggregate(in)); err != nil {
+		t.Errorf("expected the aggregate to pass, got %v", err)
+	}
+
+	tests := []struct {
+		out      []*net.IPNet
+		expected string
+	}{
+		// The same number of addresses, but not the same ones.
+		{parse("10.0.0.0/24", "10.0.3.0/24", "2001:db8::/48"), "aggregation doesn't cover 10.0.2.0/24"},
+		{parse("10.0.0.0/22", "10.0.2.0/24", "2001:db8::/48"), "aggregation has overlapping prefixes"},
+		{parse("10.0.0.0/22", "2001:db8::/48"), "aggregation covers 1208925819614629174707200 addresses but the input covers 1208925819614629174706688"},
+	}
+	for _, tt := range tests {
+		if err := checkAggregate(in, tt.out); err == nil || err.Error() != tt.expected {
+			t.Errorf("\ngot      %v\nexpected %s", err, tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--aggregate", "--aggregate-strict", "10.0.0.0/25", "10.0.0.128/25"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/24\n" {
+		t.Errorf("unexpected aggregate %q", stdout.String())
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	for s, expected := range map[string]string{"0": "0", "999": "999", "1000": "1,000", "12800": "12,800", "16777216": "16,777,216"} {
+		if got := groupDigits(s, ","); got != expected {
+			t.Errorf("%s: expected %s, got %s", s, expected, got)
+		}
+	}
+}
+
+func TestMergeAdjacent(t *testing.T) {
+	tests := []struct {
+		in       []string
+		expected []string
+	}{
+		{[]string{"10.0.0.128/25", "10.0.0.0/26", "10.0.0.0/25"}, []string{"10.0.0.0/24", "10.0.0.0/26"}},
+		{[]string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}, []string{"10.0.0.0/25", "10.0.0.128/25"}},
+		{[]string{"10.0.0.64/26", "10.0.0.128/26"}, []string{"10.0.0.64/26", "10.0.0.128/26"}},
+		{[]string{"2001:db8::/33", "2001:db8:8000::/33"}, []string{"2001:db8::/32"}},
+	}
+	for _, tt := range tests {
+		nets := []*net.IPNet{}
+		for _, cidr := range tt.in {
+			_, n, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nets = append(nets, n)
+		}
+		got := []string{}
+		for _, n := range mergeAdjacent(nets) {
+			got = append(got, n.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("%v:\ngot      %v\nexpected %v", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestCountDistinct(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--count-distinct"}, strings.NewReader("10.0.0.0/24\n10.0.0.0/25\n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "256\n" {
+		t.Errorf("expected 256 distinct addresses, got %q", stdout.String())
+	}
+}
+
+func TestExclude(t *testing.T) {
+	_, root, _ := net.ParseCIDR("0.0.0.0/0")
+
+	_, half, _ := net.ParseCIDR("0.0.0.0/1")
+	if got := exclude(root, half); len(got) != 1 || got[0].String() != "128.0.0.0/1" {
+		t.Errorf("expected complement of 0.0.0.0/1 to be 128.0.0.0/1, got %v", got)
+	}
+
+	_, slash24, _ := net.ParseCIDR("10.0.0.0/24")
+	got := exclude(root, slash24)
+	if len(got) != 24 {
+		t.Fatalf("expected 24 blocks, got %d: %v", len(got), got)
+	}
+	if got[0].String() != "0.0.0.0/5" {
+		t.Errorf("expected first block 0.0.0.0/5, got %s", got[0])
+	}
+	if c := addressCount(append(got, slash24)); c.String() != "4294967296" {
+		t.Errorf("expected complement and CIDR to cover 2^32 addresses, got %s", c)
+	}
+	if a := aggregate(append(got, slash24)); len(a) != 1 || a[0].String() != "0.0.0.0/0" {
+		t.Errorf("expected complement and CIDR to aggregate to 0.0.0.0/0, got %v", a)
+	}
+
+	_, other, _ := net.ParseCIDR("192.168.0.0/16")
+	if got := exclude(slash24, other); len(got) != 1 || got[0] != slash24 {
+		t.Errorf("expected excluding a disjoint network to change nothing, got %v", got)
+	}
+}
+
+func TestSupernet(t *testing.T) {
+	tests := []struct {
+		in       []string
+		expected string
+	}{
+		{[]string{"10.0.0.0/24", "10.0.3.0/24"}, "10.0.0.0/22"},
+		{[]string{"10.0.3.0/24", "10.0.0.0/24"}, "10.0.0.0/22"},
+		{[]string{"10.0.0.0/24"}, "10.0.0.0/24"},
+		{[]string{"10.0.0.7/24", "10.0.0.128/25"}, "10.0.0.0/24"},
+		{[]string{"0.0.0.0/1", "128.0.0.0/1"}, "0.0.0.0/0"},
+		{[]string{"2001:db8::/48", "2001:db8:ff::/48"}, "2001:db8::/40"},
+	}
+	for _, tt := range tests {
+		nets := []*net.IPNet{}
+		for _, cidr := range tt.in {
+			_, n, _ := net.ParseCIDR(cidr)
+			nets = append(nets, n)
+		}
+		got, err := supernet(nets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tt.expected {
+			t.Errorf("%v: expected %s, got %s", tt.in, tt.expected, got)
+		}
+	}
+
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	if _, err := supernet([]*net.IPNet{v4, v6}); err == nil {
+		t.Error("expected mixed versions to be an error")
+	}
+}
+
+func TestNearestAggregateWaste(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--nearest-aggregate", "10.0.0.0/24", "10.0.3.0/24"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/22\n" {
+		t.Errorf("expected 10.0.0.0/22, got %q", stdout.String())
+	}
+	if expected := "10.0.0.0/22 covers 1024 addresses, 512 more than the 512 listed\n"; stderr.String() != expected {
+		t.Errorf("\ngot      %q\nexpected %q", stderr.String(), expected)
+	}
+}
+
+func TestAggregateSorted(t *testing.T) {
+	lists := []string{
+		"10.0.0.0/24\n10.0.1.0/24\n10.0.2.0/24\n10.0.3.0/25\n10.0.3.128/25\n10.0.5.0/24\n",
+		"10.0.0.0/24\n10.0.0.0/25\n10.0.0.128/26\n10.0.1.0/24 # web\n\n192.168.0.0/24\n2001:db8::/33\n2001:db8:8000::/33\n",
+		"0.0.0.0/1\n128.0.0.0/2\n192.0.0.0/2\n::/0\n",
+		"10.0.0.1/32\n10.0.0.2/31\n10.0.0.4/30\n10.0.0.8/29\n10.0.0.16/28\n10.0.0.32/27\n10.0.0.64/26\n10.0.0.128/25\n",
+	}
+	for _, list := range lists {
+		nets, err := listNets(nil, strings.NewReader(list))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected bytes.Buffer
+		for _, n := range aggregate(nets) {
+			fmt.Fprintln(&expected, n)
+		}
+		var got bytes.Buffer
+		if err := aggregateSorted(strings.NewReader(list), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != expected.String() {
+			t.Errorf("\ngot\n%s\nexpected\n%s", got.String(), expected.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--aggregate", "--sorted-input"}, strings.NewReader("10.0.1.0/24\n10.0.0.0/24\n"), &stdout, &stderr)
+	if code == 0 || !strings.Contains(stderr.String(), "line 2: 10.0.0.0/24 comes before 10.0.1.0/24") {
+		t.Errorf("expected unsorted input to fail, got exit %d: %s", code, stderr.String())
+	}
+}
+
+func sortedBenchmarkList() string {
+	var b strings.Builder
+	for i := 0; i < 1<<16; i++ {
+		if i%7 != 0 {
+			fmt.Fprintf(&b, "10.%d.%d.0/24\n", i>>8, i&0xff)
+		}
+	}
+	return b.String()
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	list := sortedBenchmarkList()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nets, _ := listNets(nil, strings.NewReader(list))
+		for _, n := range aggregate(nets) {
+			fmt.Fprintln(io.Discard, n)
+		}
+	}
+}
+
+func BenchmarkAggregateSorted(b *testing.B) {
+	list := sortedBenchmarkList()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aggregateSorted(strings.NewReader(list), io.Discard)
+	}
+}
+
+func TestAggregateMaxLen(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := "10.0.0.0/24\n10.0.1.0/24\n10.0.2.0/25\n2001:db8::/31\n"
+	if code := run([]string{"--aggregate", "--max-len", "24"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := "10.0.0.0/24\n10.0.1.0/24\n10.0.2.0/25\n2001:db8::/31\n"
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--aggregate", "--max-len", "32", "2001:db8::/31"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if expected := "2001:db8::/32\n2001:db9::/32\n"; stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+
+	_, slash8, _ := net.ParseCIDR("10.0.0.0/8")
+	if _, err := capPrefixLen([]*net.IPNet{slash8}, 32, 65536); err == nil {
+		t.Error("expected splitting a /8 into /32s to be refused")
+	}
+	if _, err := capPrefixLen([]*net.IPNet{slash8}, 33, 0); err == nil {
+		t.Error("expected /33 to be rejected for IPv4")
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := "10.1.1.0/24\n192.168.0.0/16\n10.0.0.0/8\n10.2.0.0/16\n10.1.0.0/16\n10.1.2.0/24\n10.1.0.0/16\n2001:db8::/32\n"
+	if code := run([]string{"--hierarchy"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := `10.0.0.0/8
+  10.1.0.0/16
+    10.1.1.0/24
+    10.1.2.0/24
+  10.2.0.0/16
+192.168.0.0/16
+2001:db8::/32
+`
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+}
+
+func TestAggregationSavings(t *testing.T) {
+	in := "10.0.0.0/26\n10.0.0.64/26\n10.0.0.128/26\n10.0.0.192/26\n"
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--aggregation-savings"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if expected := "4 prefixes aggregate to 1, saved 3\n"; stdout.String() != expected {
+		t.Errorf("\ngot      %s\nexpected %s", stdout.String(), expected)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/sha256"
+	"net"
+	"strconv"
+)
+
+// anonymize returns a CIDR shaped like r, with the same version, prefix
+// length and host bits, but its network bits replaced by a hash of the
+// network and salt. The same network and salt always give the same result.
+func anonymize(r Result, salt string) string {
+	h := sha256.New()
+	h.Write([]byte(salt))
+	h.Write(r.Network)
+	h.Write([]byte{byte(r.NetMaskSize)})
+	sum := h.Sum(nil)
+
+	ip := make(net.IP, len(r.IP))
+	for i := range ip {
+		ip[i] = sum[i]&r.NetMask[i] | r.IP[i]&r.HostMask[i]
+	}
+	return ip.String() + "/" + strconv.Itoa(r.NetMaskSize)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	for _, cidr := range []string{"10.20.30.40/22", "2001:db8:85a3::8a2e:370:7334/64"} {
+		r, err := calc(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anon, err := calc(anonymize(r, "salt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if anon.IsV6 != r.IsV6 || anon.NetMaskSize != r.NetMaskSize || anon.IPCount.Cmp(r.IPCount) != 0 {
+			t.Errorf("%s: expected anonymized %s/%d to keep its shape", cidr, anon.IP, anon.NetMaskSize)
+		}
+		if anon.Network.Equal(r.Network) {
+			t.Errorf("%s: expected network %s to change", cidr, r.Network)
+		}
+		if again := anonymize(r, "salt"); again != anonymize(r, "salt") {
+			t.Errorf("%s: expected anonymization to be reproducible", cidr)
+		}
+		if anonymize(r, "salt") == anonymize(r, "pepper") {
+			t.Errorf("%s: expected a different salt to give a different result", cidr)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ip6ArpaZone returns the ip6.arpa reverse DNS zone delegated at the
+// boundary of the IPv6 network ipnet, which must fall between nibbles.
+func ip6ArpaZone(ipnet *net.IPNet) (string, error) {
+	ones, bits := ipnet.Mask.Size()
+	if bits != 8*net.IPv6len {
+		return "", fmt.Errorf("ip6.arpa zones are for IPv6 networks")
+	}
+	if ones%4 != 0 {
+		return "", fmt.Errorf("/%d is not on a nibble boundary, try /%d or /%d", ones, ones/4*4, (ones/4+1)*4)
+	}
+	return arpaName(ipnet.IP.Mask(ipnet.Mask), ones/4), nil
+}
+
+// arpaName returns the reverse DNS name for the first n labels of ip, which
+// are octets for IPv4 under in-addr.arpa and nibbles for IPv6 under ip6.arpa.
+func arpaName(ip net.IP, n int) string {
+	labels := []string{}
+	if ipv4 := ip.To4(); ipv4 != nil && len(ip) == net.IPv4len {
+		for i := n - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(ipv4[i])))
+		}
+		return strings.Join(append(labels, "in-addr.arpa"), ".")
+	}
+	digits := hex.EncodeToString(ip)[:n]
+	for i := len(digits) - 1; i >= 0; i-- {
+		labels = append(labels, digits[i:i+1])
+	}
+	return strings.Join(append(labels, "ip6.arpa"), ".")
+}
+
+// arpaZoneFile writes a reverse DNS zone file skeleton for the IPv4 network
+// in r, with placeholder SOA and NS records and a PTR record for each usable
+// host named by hostName under example.com.
+func arpaZoneFile(w io.Writer, r Result, template string, maxOutput int) error {
+	if r.IsV6 {
+		return fmt.Errorf("--arpa-zone is for IPv4 networks, see --ip6-arpa for IPv6")
+	}
+	_, _, count := usableRange(r)
+	if err := checkOutputLimit(count, maxOutput); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "$ORIGIN %s.\n", arpaName(r.Network, r.NetMaskSize/8))
+	fmt.Fprintf(w, "$TTL 3600\n")
+	if r.NetMaskSize%8 != 0 {
+		fmt.Fprintf(w, "; %s/%d is only part of this zone, delegating it needs RFC 2317\n", r.Network, r.NetMaskSize)
+	}
+	fmt.Fprintf(w, "@ IN SOA ns1.example.com. hostmaster.example.com. (1 3600 900 604800 3600)\n")
+	fmt.Fprintf(w, "@ IN NS ns1.example.com.\n")
+	return eachHost(r, maxOutput, func(ip net.IP) {
+		fmt.Fprintf(w, "%s. IN PTR %s.example.com.\n", arpaName(ip, net.IPv4len), hostName(template, ip))
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestIP6ArpaZone(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa"},
+		{"2001:db8:abcd:12::/48", "d.c.b.a.8.b.d.0.1.0.0.2.ip6.arpa"},
+		{"::/0", "ip6.arpa"},
+	}
+	for _, tt := range tests {
+		_, ipnet, _ := net.ParseCIDR(tt.cidr)
+		zone, err := ip6ArpaZone(ipnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zone != tt.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tt.cidr, zone, tt.expected)
+		}
+	}
+
+	for _, cidr := range []string{"2001:db8::/33", "10.0.0.0/8"} {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		if _, err := ip6ArpaZone(ipnet); err == nil {
+			t.Errorf("expected %s to be rejected", cidr)
+		}
+	}
+}
+
+func TestArpaZoneFile(t *testing.T) {
+	var buf bytes.Buffer
+	r, _ := calc("10.0.0.4/30")
+	if err := arpaZoneFile(&buf, r, "host-{ip}", 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := `$ORIGIN 0.0.10.in-addr.arpa.
+$TTL 3600
+; 10.0.0.4/30 is only part of this zone, delegating it needs RFC 2317
+@ IN SOA ns1.example.com. hostmaster.example.com. (1 3600 900 604800 3600)
+@ IN NS ns1.example.com.
+5.0.0.10.in-addr.arpa. IN PTR host-10-0-0-5.example.com.
+6.0.0.10.in-addr.arpa. IN PTR host-10-0-0-6.example.com.
+`
+	if buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	r, _ = calc("10.0.0.0/16")
+	if err := arpaZoneFile(&buf, r, "host-{ip}", 65533); err == nil || buf.Len() > 0 {
+		t.Error("expected a /16 to be refused by a limit of 65533, without output")
+	}
+	r, _ = calc("2001:db8::/64")
+	if err := arpaZoneFile(&buf, r, "host-{ip}", 0); err == nil {
+		t.Error("expected IPv6 to be rejected")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// base85Digits is the RFC 1924 alphabet for encoding IPv6 addresses.
+const base85Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// base85Len is how many digits an encoded address takes, since 85^20 > 2^128.
+const base85Len = 20
+
+// encodeBase85 encodes the IPv6 address ip in the 20 character RFC 1924 form.
+func encodeBase85(ip net.IP) (string, error) {
+	if ip.To4() != nil || len(ip) != net.IPv6len {
+		return "", fmt.Errorf("base 85 encoding needs an IPv6 address, got %s", ip)
+	}
+	n := ipToInt(ip)
+	base, digit := big.NewInt(85), new(big.Int)
+	b := make([]byte, base85Len)
+	for i := base85Len - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		b[i] = base85Digits[digit.Int64()]
+	}
+	return string(b), nil
+}
+
+// decodeBase85 decodes an IPv6 address encoded by encodeBase85.
+func decodeBase85(s string) (net.IP, error) {
+	if len(s) != base85Len {
+		return nil, fmt.Errorf("base 85 address %q must be %d characters", s, base85Len)
+	}
+	n, base := new(big.Int), big.NewInt(85)
+	for _, c := range s {
+		digit := strings.IndexRune(base85Digits, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("base 85 address %q has invalid character %q", s, c)
+		}
+		n.Mul(n, base).Add(n, big.NewInt(int64(digit)))
+	}
+	if n.BitLen() > 8*net.IPv6len {
+		return nil, fmt.Errorf("base 85 address %q is out of range", s)
+	}
+	return intToIP(n, net.IPv6len), nil
+}
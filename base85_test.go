@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestBase85(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"1080::8:800:200c:417a", "4)+k&C#VzJ4br>0wv%Yp"}, // RFC 1924's example
+		{"::", "00000000000000000000"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "=r54lj&NUUO~Hi%c2ym0"},
+	}
+	for _, tt := range tests {
+		encoded, err := encodeBase85(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", encoded, tt.expected)
+		}
+	}
+
+	encoded, err := encodeBase85(net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip, err := decodeBase85(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "2001:db8::1" {
+		t.Errorf("expected 2001:db8::1 to round trip, got %s from %s", ip, encoded)
+	}
+
+	if _, err := encodeBase85(net.ParseIP("10.0.0.1")); err == nil {
+		t.Error("expected IPv4 to be rejected")
+	}
+	for _, s := range []string{"4)+k&C#VzJ4br>0wv%Y", "4)+k&C#VzJ4br>0wv%Y\"", "~~~~~~~~~~~~~~~~~~~~"} {
+		if _, err := decodeBase85(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestRunBase85(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--base85", "1080::8:800:200c:417a/64"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "4)+k&C#VzJ4br>0wv%Yp\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--from-base85", "4)+k&C#VzJ4br>0wv%Yp"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "1080::8:800:200c:417a\n" {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
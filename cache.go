@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// defaultCachePath is where --compare-to-previous remembers prefixes unless
+// --cache says otherwise.
+func defaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "cidrinfo", "previous.json")
+}
+
+// comparePrevious records cidr as the prefix for label in the JSON cache at
+// path, returning the prefix previously recorded, if any.
+func comparePrevious(path, label, cidr string) (string, error) {
+	cache := map[string]string{}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(b, &cache); err != nil {
+			return "", err
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	previous := cache[label]
+	cache[label] = cidr
+	if b, err = json.MarshalIndent(cache, "", "  "); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return previous, os.WriteFile(path, append(b, '\n'), 0644)
+}
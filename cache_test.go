@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestComparePrevious(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "sub", "previous.json")
+	runs := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.1.0.0/24", ""},
+		{"10.1.0.9/24", ""},
+		{"10.1.0.0/23", "dhcp changed from 10.1.0.0/24 to 10.1.0.0/23\n"},
+	}
+	for _, tt := range runs {
+		var stdout, stderr bytes.Buffer
+		args := []string{"--compare-to-previous", "dhcp", "--cache", cache, tt.cidr}
+		if code := run(args, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+		}
+		if stderr.String() != tt.expected {
+			t.Errorf("%s: \ngot      %q\nexpected %q", tt.cidr, stderr.String(), tt.expected)
+		}
+	}
+
+	previous, err := comparePrevious(cache, "other", "2001:db8::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "" {
+		t.Errorf("expected a new label to have no previous prefix, got %s", previous)
+	}
+	if previous, _ = comparePrevious(cache, "dhcp", "10.1.0.0/23"); previous != "10.1.0.0/23" {
+		t.Errorf("expected other labels to be kept, got %q", previous)
+	}
+}
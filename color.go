@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiGreen  = "\x1b[32m"
+	ansiReset  = "\x1b[0m"
+)
+
+// tagColors highlights tags by severity, keyed by tagName: red for addresses
+// which shouldn't appear in normal use, yellow for those with limited scope,
+// and green for global unicast ones.
+var tagColors = map[string]string{
+	"unspecified":               ansiRed,
+	"reserved":                  ansiRed,
+	"this network":              ansiRed,
+	"loopback":                  ansiYellow,
+	"private":                   ansiYellow,
+	"link local unicast":        ansiYellow,
+	"link local multicast":      ansiYellow,
+	"IPv4 link-local":           ansiYellow,
+	"IPv6 link-local":           ansiYellow,
+	"interface local multicast": ansiYellow,
+	"multicast":                 ansiYellow,
+	"global unicast":            ansiGreen,
+}
+
+// useColor resolves a --color mode of always, never or auto, where auto
+// means stdout is a terminal and NO_COLOR isn't set.
+func useColor(mode string, stdout io.Writer) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(stdout)
+	}
+}
+
+func colorTag(tag string) string {
+	if color, ok := tagColors[tagName(tag)]; ok {
+		return color + tag + ansiReset
+	}
+	return tag
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completion writes a completion script for shell, generated from the flags
+// so it always lists them all.
+func completion(w io.Writer, flags *flag.FlagSet, shell string) {
+	type completionFlag struct {
+		name, usage string
+		takesValue  bool
+	}
+	var list []completionFlag
+	flags.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		list = append(list, completionFlag{f.Name, usage, !ok || !b.IsBoolFlag()})
+	})
+
+	switch shell {
+	case "bash":
+		names := make([]string, len(list))
+		for i, f := range list {
+			names[i] = "--" + f.name
+		}
+		fmt.Fprintf(w, "_cidrinfo() {\n")
+		fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]}\n")
+		fmt.Fprintf(w, "\tif [[ $cur == -* ]]; then\n")
+		fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+		fmt.Fprintf(w, "\tfi\n")
+		fmt.Fprintf(w, "}\n")
+		fmt.Fprintf(w, "complete -o default -F _cidrinfo cidrinfo\n")
+	case "zsh":
+		fmt.Fprintf(w, "#compdef cidrinfo\n\n_arguments \\\n")
+		escape := strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`, "'", `'\''`)
+		for _, f := range list {
+			value := ""
+			if f.takesValue {
+				value = ": :"
+			}
+			fmt.Fprintf(w, "\t'--%s[%s]%s' \\\n", f.name, escape.Replace(f.usage), value)
+		}
+		fmt.Fprintf(w, "\t'*:CIDR:'\n")
+	case "fish":
+		escape := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+		for _, f := range list {
+			required := ""
+			if f.takesValue {
+				required = " -r"
+			}
+			fmt.Fprintf(w, "complete -c cidrinfo -l %s%s -d '%s'\n", f.name, required, escape.Replace(f.usage))
+		}
+	}
+}
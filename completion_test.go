@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--completion", shell}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", shell, code, stderr.String())
+		}
+		for _, name := range []string{"json", "split", "aggregate", "completion"} {
+			expected := "--" + name
+			if shell == "fish" {
+				expected = "-l " + name
+			}
+			if !strings.Contains(stdout.String(), expected) {
+				t.Errorf("%s: expected %q in\n%s", shell, expected, stdout.String())
+			}
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--completion", "tcsh"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected an unknown shell to be rejected")
+	}
+}
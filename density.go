@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// freeBlocks returns the aggregated blocks of parent not covered by allocs,
+// which must all be within parent.
+func freeBlocks(parent *net.IPNet, allocs []*net.IPNet) ([]*net.IPNet, error) {
+	free := []*net.IPNet{parent}
+	for _, a := range aggregate(allocs) {
+		if !netContains(parent, a) {
+			return nil, fmt.Errorf("%s is not within %s", a, parent)
+		}
+		remaining := []*net.IPNet{}
+		for _, f := range free {
+			if !netContains(a, f) {
+				remaining = append(remaining, exclude(f, a)...)
+			}
+		}
+		free = remaining
+	}
+	return aggregate(free), nil
+}
+
+// prefixDensity writes how much of parent allocs use, and its largest free
+// block, the lowest if there are several of that size.
+func prefixDensity(w io.Writer, parent *net.IPNet, allocs []*net.IPNet) error {
+	free, err := freeBlocks(parent, allocs)
+	if err != nil {
+		return err
+	}
+	total := addressCount([]*net.IPNet{parent})
+	freeCount := addressCount(free)
+	used := new(big.Int).Sub(total, freeCount)
+
+	fmt.Fprintf(w, "     Allocated:  %s of %s (%s%%)\n", used, total, percent(new(big.Rat).SetFrac(used, total)))
+	fmt.Fprintf(w, "          Free:  %s (%s%%)\n", freeCount, percent(new(big.Rat).SetFrac(freeCount, total)))
+	var largest *net.IPNet
+	for _, f := range free {
+		if largest == nil || addressCount([]*net.IPNet{f}).Cmp(addressCount([]*net.IPNet{largest})) > 0 {
+			largest = f
+		}
+	}
+	if largest == nil {
+		fmt.Fprintln(w, "  Largest free:  none")
+	} else {
+		fmt.Fprintf(w, "  Largest free:  %s\n", netString(largest))
+	}
+	return nil
+}
+
+// allocate assigns a subnet of each of the prefix lengths in turn from parent,
+// first fit: each is the lowest one not overlapping those before it.
+func allocate(parent *net.IPNet, prefixes []string) ([]*net.IPNet, error) {
+	free := []*net.IPNet{parent}
+	allocs := []*net.IPNet{}
+	for _, prefix := range prefixes {
+		newLen, err := parsePrefixLen(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPrefixBoundary(parent, newLen); err != nil {
+			return nil, err
+		}
+		// free is kept in order, so the first block big enough holds the
+		// lowest free subnet.
+		i := 0
+		for ; i < len(free); i++ {
+			if ones, _ := free[i].Mask.Size(); ones <= newLen {
+				break
+			}
+		}
+		if i == len(free) {
+			return nil, fmt.Errorf("no room left in %s for a /%d after allocating %d subnets", parent, newLen, len(allocs))
+		}
+		alloc := subnets(free[i], newLen, 1)[0]
+		allocs = append(allocs, alloc)
+		free = append(append(free[:i:i], exclude(free[i], alloc)...), free[i+1:]...)
+		sort.Slice(free, func(i, j int) bool { return netLess(free[i], free[j]) })
+	}
+	return allocs, nil
+}
+
+// writeAllocation writes the subnets allocate assigns, and the free blocks of
+// parent left over.
+func writeAllocation(w io.Writer, parent *net.IPNet, prefixes []string) error {
+	allocs, err := allocate(parent, prefixes)
+	if err != nil {
+		return err
+	}
+	for _, a := range allocs {
+		fmt.Fprintln(w, netString(a))
+	}
+	free, err := freeBlocks(parent, allocs)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, f := range free {
+		names = append(names, netString(f))
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	fmt.Fprintf(w, "Free: %s\n", strings.Join(names, " "))
+	return nil
+}
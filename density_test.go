@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestPrefixDensity(t *testing.T) {
+	tests := []struct {
+		args     []string
+		in       string
+		expected string
+	}{
+		{
+			[]string{"10.0.0.0/24", "10.0.0.0/25"}, "",
+			"     Allocated:  128 of 256 (50%)\n          Free:  128 (50%)\n  Largest free:  10.0.0.128/25\n",
+		},
+		{
+			[]string{"10.0.0.0/24"}, "10.0.0.64/26\n10.0.0.192/27\n10.0.0.200/30\n",
+			"     Allocated:  96 of 256 (37.5%)\n          Free:  160 (62.5%)\n  Largest free:  10.0.0.0/26\n",
+		},
+		{
+			[]string{"10.0.0.0/24", "10.0.0.0/25", "10.0.0.128/25"}, "",
+			"     Allocated:  256 of 256 (100%)\n          Free:  0 (0%)\n  Largest free:  none\n",
+		},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"--prefix-density"}, tt.args...)
+		if code := run(args, strings.NewReader(tt.in), &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d: %s", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%v:\ngot\n%s\nexpected\n%s", tt.args, stdout.String(), tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--prefix-density", "10.0.0.0/24", "10.0.1.0/25"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected an allocation outside the parent to fail")
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"10.0.0.0/24", "--alloc", "/26", "--alloc", "/27", "--alloc", "/26"}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := "10.0.0.0/26\n10.0.0.64/27\n10.0.0.128/26\nFree: 10.0.0.96/27 10.0.0.192/26\n"
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+
+	// A gap left by a larger allocation is filled first.
+	_, parent, _ := net.ParseCIDR("10.0.0.0/24")
+	allocs, err := allocate(parent, []string{"/27", "/25", "/27"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, a := range allocs {
+		got = append(got, a.String())
+	}
+	if expected := "10.0.0.0/27 10.0.0.128/25 10.0.0.32/27"; strings.Join(got, " ") != expected {
+		t.Errorf("\ngot      %v\nexpected %s", got, expected)
+	}
+
+	if _, err := allocate(parent, []string{"/25", "/25", "/26"}); err == nil {
+		t.Error("expected running out of space to fail")
+	}
+	if _, err := allocate(parent, []string{"/23"}); err == nil {
+		t.Error("expected allocating more than the parent to fail")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+)
+
+// diffLists writes the union of two lists in order, each CIDR prefixed with
+// - when only in the old list, + when only in the new one, or a space when in
+// both. CIDRs are compared by value so host bits and formatting don't
+// matter. With unmap, IPv4-mapped IPv6 prefixes like ::ffff:10.0.0.0/120
+// match their IPv4 equivalent, here 10.0.0.0/24.
+func diffLists(w io.Writer, oldEntries, newEntries []listEntry, unmap bool) error {
+	oldNets, err := parseList(oldEntries)
+	if err != nil {
+		return fmt.Errorf("old list: %v", err)
+	}
+	newNets, err := parseList(newEntries)
+	if err != nil {
+		return fmt.Errorf("new list: %v", err)
+	}
+
+	const inOld, inNew = 1, 2
+	in := map[string]int{}
+	all := []*net.IPNet{}
+	add := func(nets []*net.IPNet, which int) {
+		for _, n := range nets {
+			if unmap {
+				n = unmapIPv4(n)
+			}
+			key := netKey(n)
+			if in[key] == 0 {
+				all = append(all, n)
+			}
+			in[key] |= which
+		}
+	}
+	add(oldNets, inOld)
+	add(newNets, inNew)
+	sort.Slice(all, func(i, j int) bool { return netLess(all[i], all[j]) })
+
+	for _, n := range all {
+		prefix := " "
+		switch in[netKey(n)] {
+		case inOld:
+			prefix = "-"
+		case inNew:
+			prefix = "+"
+		}
+		fmt.Fprintf(w, "%s%s\n", prefix, netString(n))
+	}
+	return nil
+}
+
+// netKey identifies a network by its address length, masked address and
+// prefix length.
+func netKey(n *net.IPNet) string {
+	ones, _ := n.Mask.Size()
+	return fmt.Sprintf("%d %x/%d", len(n.IP), []byte(n.IP.Mask(n.Mask)), ones)
+}
+
+// netString is like n.String() except IPv4-mapped IPv6 prefixes stay in IPv6
+// notation rather than being shown as if they were IPv4.
+func netString(n *net.IPNet) string {
+	ones, _ := n.Mask.Size()
+	return fmt.Sprintf("%s/%d", ipString(n.IP.Mask(n.Mask)), ones)
+}
+
+// ipString is like ip.String() except IPv4-mapped IPv6 addresses stay in IPv6
+// notation, e.g. ::ffff:1.2.3.4.
+func ipString(ip net.IP) string {
+	if len(ip) == net.IPv6len && ip.To4() != nil {
+		return "::ffff:" + ip.To4().String()
+	}
+	return ip.String()
+}
+
+// unmapIPv4 returns the IPv4 equivalent of an IPv4-mapped IPv6 prefix, or n
+// itself for any other network.
+func unmapIPv4(n *net.IPNet) *net.IPNet {
+	ones, bits := n.Mask.Size()
+	if bits != 8*net.IPv6len || ones < 96 || n.IP.To4() == nil {
+		return n
+	}
+	return &net.IPNet{IP: n.IP.To4(), Mask: net.CIDRMask(ones-96, 32)}
+}
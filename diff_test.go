@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffLists(t *testing.T) {
+	oldList, _ := readList(strings.NewReader("10.0.0.0/24\n10.0.1.0/24\n2001:DB8::/32\n::ffff:192.168.0.0/112\n"))
+	newList, _ := readList(strings.NewReader("10.0.1.9/24 # host bits\n10.0.2.0/24\n2001:db8::/32\n192.168.0.0/16\n"))
+
+	var buf bytes.Buffer
+	if err := diffLists(&buf, oldList, newList, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := `-10.0.0.0/24
+ 10.0.1.0/24
++10.0.2.0/24
++192.168.0.0/16
+-::ffff:192.168.0.0/112
+ 2001:db8::/32
+`
+	if buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := diffLists(&buf, oldList, newList, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\n 192.168.0.0/16\n") {
+		t.Errorf("expected mapped prefix to match its IPv4 equivalent in\n%s", buf.String())
+	}
+
+	bad, _ := readList(strings.NewReader("bogus\n"))
+	if err := diffLists(&buf, oldList, bad, false); err == nil || !strings.HasPrefix(err.Error(), "new list: line 1:") {
+		t.Errorf("expected new list line 1 error, got %v", err)
+	}
+}
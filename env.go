@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// argsEnv names the environment variable giving default arguments.
+const argsEnv = "CIDRINFO_ARGS"
+
+// envArgs returns args, or if there are none the arguments split from env,
+// the value of argsEnv.
+func envArgs(args []string, env string) ([]string, error) {
+	if len(args) > 0 || strings.TrimSpace(env) == "" {
+		return args, nil
+	}
+	split, err := splitArgs(env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", argsEnv, err)
+	}
+	return split, nil
+}
+
+// splitArgs splits s into words like a POSIX shell, without any expansion:
+// words are separated by whitespace unless quoted or escaped, with
+// backslashes escaping the next character outside single quotes.
+func splitArgs(s string) ([]string, error) {
+	args := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+	}{
+		{"", []string{}},
+		{"  --json   10.0.0.0/8 ", []string{"--json", "10.0.0.0/8"}},
+		{`--name-template 'a b' "c d"`, []string{"--name-template", "a b", "c d"}},
+		{`10.0.0.0\\10 '10.0.0.0\10' "10.0.0.0\10"`, []string{`10.0.0.0\10`, `10.0.0.0\10`, `10.0.0.0\10`}},
+		{`a\ b "x\"y" ''`, []string{"a b", `x"y`, ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s:\ngot      %q\nexpected %q", tt.s, got, tt.expected)
+		}
+	}
+	for _, s := range []string{`'open`, `"open`, `trailing\`} {
+		if _, err := splitArgs(s); err == nil {
+			t.Errorf("expected %s to be rejected", s)
+		}
+	}
+}
+
+func TestEnvArgs(t *testing.T) {
+	t.Setenv(argsEnv, "--count-subnets '/24' 10.0.0.0/16")
+	args, err := envArgs(nil, os.Getenv(argsEnv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "256\n" {
+		t.Errorf("expected the environment's arguments to be used, got %q", stdout.String())
+	}
+
+	args, _ = envArgs([]string{"10.0.0.0/8"}, os.Getenv(argsEnv))
+	if !reflect.DeepEqual(args, []string{"10.0.0.0/8"}) {
+		t.Errorf("expected command line arguments to override, got %q", args)
+	}
+}
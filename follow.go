@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// follower reads a file as it grows, like tail -f, waiting at the end for
+// more rather than returning io.EOF. When the file is truncated it reads
+// again from the start, and when it's replaced, as log rotation does, it
+// switches to the new file.
+type follower struct {
+	path   string
+	file   *os.File
+	offset int64
+	poll   time.Duration
+}
+
+// newFollower follows the file at path from its current end.
+func newFollower(path string, poll time.Duration) (*follower, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &follower{path: path, file: file, offset: offset, poll: poll}, nil
+}
+
+func (f *follower) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		if n > 0 {
+			f.offset += int64(n)
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		time.Sleep(f.poll)
+		if err := f.checkMoved(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// checkMoved starts reading again from the start of the file if it has been
+// truncated or replaced. A missing file is waited for, since rotation may
+// not have created the new one yet.
+func (f *follower) checkMoved() error {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, current) {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		f.file.Close()
+		f.file, f.offset = file, 0
+		return nil
+	}
+	if info.Size() < f.offset {
+		f.offset, err = f.file.Seek(0, io.SeekStart)
+	}
+	return err
+}
+
+func (f *follower) Close() error {
+	return f.file.Close()
+}
+
+// followLines reports on each CIDR in the lines read from r until it ends,
+// writing errors for lines which aren't CIDRs to errs and carrying on.
+func followLines(r io.Reader, w, errs io.Writer, ro reportOptions) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		cidr := listText(scanner.Text())
+		if cidr == "" {
+			continue
+		}
+		if err := report(w, cidr, ro); err != nil {
+			fmt.Fprintln(errs, err)
+		}
+	}
+	return scanner.Err()
+}
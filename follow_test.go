@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// growingReader returns one chunk per Read, like a file being appended to.
+type growingReader struct {
+	chunks []string
+}
+
+func (g *growingReader) Read(p []byte) (int, error) {
+	if len(g.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, g.chunks[0])
+	g.chunks = g.chunks[1:]
+	return n, nil
+}
+
+func TestFollowLines(t *testing.T) {
+	r := &growingReader{chunks: []string{"10.0.0.0/24\n", "# comment\n10.0", ".1.0/24\n", "nope\n192.168.0.0/16\n"}}
+	var stdout, stderr bytes.Buffer
+	if err := followLines(r, &stdout, &stderr, reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cidr := range []string{"10.0.0.0/24", "10.0.1.0/24", "192.168.0.0/16"} {
+		if !strings.Contains(stdout.String(), "          CIDR:  "+cidr+"\n") {
+			t.Errorf("expected a report on %s in\n%s", cidr, stdout.String())
+		}
+	}
+	if strings.Count(stdout.String(), "CIDR:") != 3 {
+		t.Errorf("expected 3 reports, got\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "nope") {
+		t.Errorf("expected an error for the invalid line, got %q", stderr.String())
+	}
+}
+
+func TestFollowLinesOnly(t *testing.T) {
+	r := &growingReader{chunks: []string{"10.0.0.0/24\n2001:db8", "::/48\n", "192.168.0.0/16\n"}}
+	var stdout, stderr bytes.Buffer
+	if err := followLines(onlyVersion(r, "v6"), &stdout, &stderr, reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(stdout.String(), "CIDR:") != 1 || !strings.Contains(stdout.String(), "          CIDR:  2001:db8::/48\n") {
+		t.Errorf("expected only the IPv6 report, got\n%s", stdout.String())
+	}
+}
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cidrs.log")
+	if err := os.WriteFile(path, []byte("10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := newFollower(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := make(chan string)
+	go func() {
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- line
+		}
+	}()
+	expect := func(expected string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("got %q, expected %q", line, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+
+	appendLine := func(line string) {
+		t.Helper()
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(line)
+		file.Close()
+	}
+	appendLine("10.1.0.0/16\n")
+	expect("10.1.0.0/16\n")
+
+	// Truncated and rewritten shorter than what has been read.
+	if err := os.WriteFile(path, []byte("10.2.0.0/16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("10.2.0.0/16\n")
+
+	// Rotated away and replaced.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("10.3.0.0/16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("10.3.0.0/16\n")
+}
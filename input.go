@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// parseCIDR is net.ParseCIDR also accepting inverse notation, where the
+// number after a backslash counts host bits e.g. 10.0.0.0\10 is 10.0.0.0/22,
+// and IPv4 dotted masks after the slash e.g. 10.0.0.0/255.255.252.0.
+// A trailing slash without a prefix length, like 10.0.0.1/, is a likely typo
+// rather than a host route, so it's an error suggesting the host route.
+func parseCIDR(s string) (net.IP, *net.IPNet, error) {
+	if addr := strings.TrimSuffix(s, "/"); addr != s {
+		if ip := net.ParseIP(addr); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil && !strings.Contains(addr, ":") {
+				bits = 8 * net.IPv4len
+			}
+			return nil, nil, fmt.Errorf("%s is missing a prefix length after the /, e.g. %s/%d for a single host", s, addr, bits)
+		}
+	}
+	if i := strings.Index(s, "/"); i >= 0 && strings.Contains(s[i+1:], ".") {
+		mask := net.ParseIP(s[i+1:]).To4()
+		if mask == nil || net.ParseIP(s[:i]).To4() == nil || strings.Contains(s, ":") {
+			return nil, nil, &net.ParseError{Type: "CIDR address", Text: s}
+		}
+		if !isContiguousMask(net.IPMask(mask)) {
+			return nil, nil, fmt.Errorf("%s: netmask %s is not contiguous", s, mask)
+		}
+		ones, _ := net.IPMask(mask).Size()
+		s = s[:i] + "/" + strconv.Itoa(ones)
+	}
+	if i := strings.Index(s, `\`); i >= 0 {
+		ip := net.ParseIP(s[:i])
+		hostBits, err := strconv.Atoi(s[i+1:])
+		if ip == nil || err != nil {
+			return nil, nil, &net.ParseError{Type: "CIDR address", Text: s}
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil && !strings.Contains(s[:i], ":") {
+			bits = 8 * net.IPv4len
+		}
+		if hostBits < 0 || hostBits > bits {
+			return nil, nil, fmt.Errorf("%s: host bits must be between 0 and %d", s, bits)
+		}
+		s = s[:i] + "/" + strconv.Itoa(bits-hostBits)
+	}
+	return net.ParseCIDR(s)
+}
+
+// Policies for IPv4-mapped IPv6 addresses like ::ffff:1.2.3.4, chosen with
+// --version-policy.
+const (
+	mappedAsV4 = "mapped-as-v4"
+	strictV6   = "strict-v6"
+)
+
+// applyVersionPolicy returns ip and ipnet as IPv4 if ipnet is an IPv4-mapped
+// IPv6 network of /96 or longer, unless policy is strictV6. Shorter mapped
+// networks aren't all IPv4 addresses so they always stay IPv6.
+func applyVersionPolicy(ip net.IP, ipnet *net.IPNet, policy string) (net.IP, *net.IPNet) {
+	if policy == strictV6 {
+		return ip, ipnet
+	}
+	if v4 := unmapIPv4(ipnet); v4 != ipnet {
+		return ip.To4(), v4
+	}
+	return ip, ipnet
+}
+
+// splitPort separates a :port suffix from a CIDR copied from a config, like
+// 10.0.0.0/24:8080. IPv6 addresses need brackets, as in [2001:db8::]/48:443
+// or [2001:db8::/48]:443, so their colons aren't mistaken for the port's.
+// The port is "" if there isn't one.
+func splitPort(s string) (cidr, port string, err error) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return "", "", fmt.Errorf("%s is missing the ] after the IPv6 address", s)
+		}
+		cidr, rest := s[1:end], s[end+1:]
+		if i := strings.Index(rest, ":"); i >= 0 {
+			cidr, port = cidr+rest[:i], rest[i+1:]
+		} else {
+			cidr += rest
+		}
+		return cidr, port, checkPort(s, port)
+	}
+	slash, colon := strings.Index(s, "/"), strings.LastIndex(s, ":")
+	if slash < 0 || colon < slash || strings.Contains(s[:slash], ":") {
+		return s, "", nil
+	}
+	return s[:colon], s[colon+1:], checkPort(s, s[colon+1:])
+}
+
+func checkPort(s, port string) error {
+	if port == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("%s has an invalid port %q, expected 0 to 65535", s, port)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseCIDRInverse(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{`10.0.0.0\10`, "10.0.0.0/22"},
+		{`10.0.0.0\0`, "10.0.0.0/32"},
+		{`10.0.0.0\32`, "0.0.0.0/0"},
+		{`2001:db8::\64`, "2001:db8::/64"},
+		{"10.0.0.0/22", "10.0.0.0/22"},
+		{"10.0.0.0/255.255.255.0", "10.0.0.0/24"},
+		{"10.0.0.0/255.255.252.0", "10.0.0.0/22"},
+		{"10.0.0.0/0.0.0.0", "0.0.0.0/0"},
+	}
+	for _, tt := range tests {
+		_, ipnet, err := parseCIDR(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if ipnet.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.in, tt.expected, ipnet)
+		}
+	}
+
+	for _, in := range []string{`10.0.0.0\33`, `10.0.0.0\-1`, `10.0.0.0\x`, `10.0.0\8`, `2001:db8::\129`, "10.0.0.0/255.0.255.0", "10.0.0.0/255.255.255", "2001:db8::/255.255.255.0", "::ffff:10.0.0.0/255.255.255.0", "10.0.0.0/::ffff:255.255.255.0"} {
+		if _, _, err := parseCIDR(in); err == nil {
+			t.Errorf("expected %s to be rejected", in)
+		}
+	}
+}
+
+func TestParseCIDRTrailingSlash(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"10.0.0.1/", "10.0.0.1/ is missing a prefix length after the /, e.g. 10.0.0.1/32 for a single host"},
+		{"2001:db8::1/", "2001:db8::1/ is missing a prefix length after the /, e.g. 2001:db8::1/128 for a single host"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseCIDR(tt.in)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s:\ngot      %v\nexpected %s", tt.in, err, tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.1/"}, nil, &stdout, &stderr); code != exitFailure {
+		t.Errorf("expected exit %d, got %d", exitFailure, code)
+	}
+	if stderr.String() != tests[0].expected+"\n" {
+		t.Errorf("expected just the error on stderr, got %q", stderr.String())
+	}
+}
+
+func TestSplitPort(t *testing.T) {
+	tests := []struct {
+		in         string
+		cidr, port string
+	}{
+		{"10.0.0.0/24:8080", "10.0.0.0/24", "8080"},
+		{"10.0.0.0/24", "10.0.0.0/24", ""},
+		{"[2001:db8::]/48:443", "2001:db8::/48", "443"},
+		{"[2001:db8::/48]:443", "2001:db8::/48", "443"},
+		{"[2001:db8::]/48", "2001:db8::/48", ""},
+		{"2001:db8::/48", "2001:db8::/48", ""},
+	}
+	for _, tt := range tests {
+		cidr, port, err := splitPort(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.in, err)
+		}
+		if cidr != tt.cidr || port != tt.port {
+			t.Errorf("%s: got %s port %q, expected %s port %q", tt.in, cidr, port, tt.cidr, tt.port)
+		}
+	}
+	for _, in := range []string{"10.0.0.0/24:http", "10.0.0.0/24:65536", "[2001:db8::/48"} {
+		if _, _, err := splitPort(in); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+
+	for _, tt := range []struct{ in, cidr, port string }{
+		{"10.0.0.0/24:8080", "10.0.0.0/24", "8080"},
+		{"[2001:db8::]/48:443", "2001:db8::/48", "443"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--strip-port", tt.in}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", tt.in, code, stderr.String())
+		}
+		if expected := "          CIDR:  " + tt.cidr + "\n          Port:  " + tt.port + "\n"; !strings.Contains(stdout.String(), expected) {
+			t.Errorf("%s: expected %q in\n%s", tt.in, expected, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+)
+
+// jsonResult is the JSON representation of a Result. The desc and pattern
+// tags feed jsonSchema, so the schema always describes what is marshaled.
+type jsonResult struct {
+	IP           string   `json:"ip" desc:"IP address as given, host bits intact"`
+	Version      int      `json:"version" desc:"IP version, 4 or 6"`
+	IPBits       int      `json:"ipBits" desc:"number of bits in an address of this version"`
+	Network      string   `json:"network" desc:"network address; the first IP"`
+	NetMask      string   `json:"netMask" desc:"network mask in address form"`
+	NetMaskSize  int      `json:"netMaskSize" desc:"prefix length"`
+	HostMask     string   `json:"hostMask" desc:"host mask in address form"`
+	HostMaskSize int      `json:"hostMaskSize" desc:"number of host bits"`
+	Broadcast    string   `json:"broadcast" desc:"highest address; the last IP"`
+	IPCount      string   `json:"ipCount" desc:"number of IPs as a decimal string, which may exceed 64 bits" pattern:"^[0-9]+$"`
+	UsableCount  string   `json:"usableCount" desc:"number of IPs assignable to hosts, excluding the IPv4 network and broadcast addresses except in a /31 or /32" pattern:"^[0-9]+$"`
+	Tags         []string `json:"tags" desc:"special-purpose address types"`
+	Label        string   `json:"label,omitempty" desc:"comment following the CIDR on its line of a list, if any"`
+}
+
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.jsonResult())
+}
+
+func (r Result) jsonResult() jsonResult {
+	version := 4
+	if r.IsV6 {
+		version = 6
+	}
+	_, _, usable := usableRange(r)
+	return jsonResult{
+		IP:           ipString(r.IP),
+		Version:      version,
+		IPBits:       r.IPBits,
+		Network:      ipString(r.Network),
+		NetMask:      maskString(r.NetMask),
+		NetMaskSize:  r.NetMaskSize,
+		HostMask:     maskString(r.HostMask),
+		HostMaskSize: r.HostMaskSize,
+		Broadcast:    ipString(r.Max),
+		IPCount:      r.IPCount.String(),
+		UsableCount:  usable.String(),
+		Tags:         r.Tags,
+	}
+}
+
+// maskString is m in address form. IPv6 masks are compressed rather than
+// printed by net.IP, which shows masks like ::ffff:ffff:ffff in IPv4 form.
+func maskString(m net.IPMask) string {
+	if len(m) == net.IPv6len {
+		return compressIPv6(net.IP(m))
+	}
+	return net.IP(m).String()
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// selectFields returns the comma separated fields of r's JSON representation.
+func selectFields(r Result, fields string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	selected := map[string]json.RawMessage{}
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		v, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", name, strings.Join(jsonFieldNames(), ", "))
+		}
+		selected[name] = v
+	}
+	return selected, nil
+}
+
+func jsonFieldNames() []string {
+	names := []string{}
+	t := reflect.TypeOf(jsonResult{})
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, jsonFieldName(t.Field(i)))
+	}
+	return names
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+func jsonSchema() ([]byte, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+	t := reflect.TypeOf(jsonResult{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonFieldName(f)
+		property := map[string]interface{}{
+			"type":        jsonSchemaType(f.Type),
+			"description": f.Tag.Get("desc"),
+		}
+		if pattern := f.Tag.Get("pattern"); pattern != "" {
+			property["pattern"] = pattern
+		}
+		if f.Type.Kind() == reflect.Slice {
+			property["items"] = map[string]interface{}{"type": jsonSchemaType(f.Type.Elem())}
+		}
+		properties[name] = property
+		if !strings.HasSuffix(f.Tag.Get("json"), ",omitempty") {
+			required = append(required, name)
+		}
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "cidrinfo result",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, "", "  ")
+}
+
+func jsonSchemaType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "integer"
+	case reflect.Slice:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// jsonList reads a JSON array of CIDR strings from r and writes a JSON array
+// of their results to w, skipping CIDRs of the other IP version to only.
+func jsonList(r io.Reader, w io.Writer, only string) error {
+	var cidrs []string
+	if err := json.NewDecoder(r).Decode(&cidrs); err != nil {
+		return fmt.Errorf("expected a JSON array of CIDR strings: %v", err)
+	}
+	results := []Result{}
+	for i, cidr := range cidrs {
+		if only != "" && !isVersion(cidr, only) {
+			continue
+		}
+		r, err := calc(cidr)
+		if err != nil {
+			return fmt.Errorf("element %d: %v", i, err)
+		}
+		results = append(results, r)
+	}
+	return writeJSON(w, results)
+}
+
+// jsonLines writes the result for each listed CIDR as a JSON object on its
+// own line, labeled with its comment in the list.
+func jsonLines(w io.Writer, entries []listEntry) error {
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		r, err := calc(e.cidr)
+		if err != nil {
+			return e.err(err)
+		}
+		jr := r.jsonResult()
+		jr.Label = e.label
+		if err := enc.Encode(jr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
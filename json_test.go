@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	r, err := calc("10.20.30.40/22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"ip":"10.20.30.40","version":4,"ipBits":32,"network":"10.20.28.0","netMask":"255.255.252.0","netMaskSize":22,"hostMask":"0.0.3.255","hostMaskSize":10,"broadcast":"10.20.31.255","ipCount":"1024","usableCount":"1022","tags":["private"]}`
+	if string(b) != expected {
+		t.Errorf("\ngot      %s\nexpected %s", b, expected)
+	}
+
+	// A /80 host mask looks like an IPv4-mapped address to net.IP.
+	r, err = calc("2001:db8::/80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jr := r.jsonResult()
+	if jr.NetMask != "ffff:ffff:ffff:ffff:ffff::" || jr.HostMask != "::ffff:ffff:ffff" {
+		t.Errorf("unexpected masks %q and %q", jr.NetMask, jr.HostMask)
+	}
+}
+
+func TestMarshalJSONUsableCount(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.0.0.0/24", "254"},
+		{"10.0.0.0/31", "2"},
+		{"10.0.0.1/32", "1"},
+		{"2001:db8::/64", "18446744073709551616"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got jsonResult
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.UsableCount != tt.expected {
+			t.Errorf("%s: expected usableCount %s, got %s", tt.cidr, tt.expected, got.UsableCount)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := jsonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Type       string
+		Properties map[string]struct {
+			Type    string
+			Pattern string
+		}
+		Required []string
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.Type != "object" {
+		t.Errorf("expected type object, got %q", schema.Type)
+	}
+
+	r, err := calc("2001:db8::/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name := range fields {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("marshaled field %q missing from schema", name)
+		}
+	}
+	// Only the optional label, for list entries, isn't always marshaled.
+	if len(schema.Properties) != len(fields)+1 || len(schema.Required) != len(fields) {
+		t.Errorf("expected %d schema properties, got %d (%d required)", len(fields)+1, len(schema.Properties), len(schema.Required))
+	}
+	if _, ok := schema.Properties["label"]; !ok {
+		t.Error("expected an optional label property")
+	}
+	if p := schema.Properties["ipCount"]; p.Type != "string" || p.Pattern == "" {
+		t.Errorf("expected ipCount to be a patterned string, got %+v", p)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	r, err := calc("10.20.30.40/22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := selectFields(r, "network,ipCount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["network"] != "10.20.28.0" || got["ipCount"] != "1024" {
+		t.Errorf("expected exactly network and ipCount, got %s", b)
+	}
+
+	if _, err := selectFields(r, "network,bogus"); err == nil {
+		t.Error("expected unknown field to be an error")
+	}
+}
+
+func TestStdinJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := `["10.0.0.0/24", "2001:db8::/64"]`
+	if code := run([]string{"--stdin-json"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	var results []jsonResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Network != "10.0.0.0" || results[0].IPCount != "256" {
+		t.Errorf("unexpected first result %+v", results[0])
+	}
+	if results[1].Network != "2001:db8::" || results[1].Version != 6 {
+		t.Errorf("unexpected second result %+v", results[1])
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"--stdin-json"}, strings.NewReader(`["10.0.0.0/24"`), &stdout, &stderr); code == 0 {
+		t.Error("expected malformed JSON to fail")
+	}
+	if !strings.Contains(stderr.String(), "expected a JSON array of CIDR strings") {
+		t.Errorf("unexpected error %q", stderr.String())
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := "10.0.0.0/24  # web tier\n# skipped\n192.168.1.1/16\n2001:db8::/64\n"
+	if code := run([]string{"--json-lines"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), stdout.String())
+	}
+	for i, expected := range []string{"10.0.0.0", "192.168.0.0", "2001:db8::"} {
+		var r jsonResult
+		if err := json.Unmarshal([]byte(lines[i]), &r); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if r.Network != expected {
+			t.Errorf("line %d: expected network %s, got %s", i+1, expected, r.Network)
+		}
+	}
+	if !strings.Contains(lines[0], `"label":"web tier"`) {
+		t.Errorf("expected the first line to be labeled web tier, got %s", lines[0])
+	}
+	if strings.Contains(lines[1], `"label"`) {
+		t.Errorf("expected no label on an uncommented line, got %s", lines[1])
+	}
+}
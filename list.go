@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// listEntry is a CIDR from a list, along with the line it was read from, or
+// zero for CIDRs given as arguments, and any comment after it as a label.
+type listEntry struct {
+	line  int
+	cidr  string
+	label string
+}
+
+// readList reads one CIDR per line, ignoring blank lines and # comments.
+func readList(r io.Reader) ([]listEntry, error) {
+	entries := []listEntry{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if text := listText(scanner.Text()); text != "" {
+			entries = append(entries, listEntry{line: line, cidr: text, label: listLabel(scanner.Text())})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// listText returns the CIDR on a line of a list, or "" if there isn't one.
+func listText(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// listLabel returns the comment on a line of a list, like "web tier" for
+// 10.0.0.0/24  # web tier.
+func listLabel(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		return strings.TrimSpace(line[i+1:])
+	}
+	return ""
+}
+
+// listInput returns args as list entries if there are any, otherwise the
+// entries read from r.
+func listInput(args []string, r io.Reader) ([]listEntry, error) {
+	if len(args) > 0 {
+		entries := make([]listEntry, len(args))
+		for i, arg := range args {
+			entries[i] = listEntry{cidr: arg}
+		}
+		return entries, nil
+	}
+	return readList(r)
+}
+
+// listNets parses the CIDRs given as args, or otherwise listed in r.
+func listNets(args []string, r io.Reader) ([]*net.IPNet, error) {
+	entries, err := listInput(args, r)
+	if err != nil {
+		return nil, err
+	}
+	return parseList(entries)
+}
+
+func parseList(entries []listEntry) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, len(entries))
+	for i, e := range entries {
+		_, ipnet, err := parseCIDR(e.cidr)
+		if err != nil {
+			return nil, e.err(err)
+		}
+		nets[i] = ipnet
+	}
+	return nets, nil
+}
+
+func (e listEntry) err(err error) error {
+	if e.line == 0 {
+		return err
+	}
+	return fmt.Errorf("line %d: %v", e.line, err)
+}
+
+// versionFilter blanks the lines of a list with CIDRs of the other IP
+// version to --only, keeping the line numbers of the rest. Lines which don't
+// parse are kept for the error.
+type versionFilter struct {
+	r    *bufio.Reader
+	only string
+	buf  []byte
+}
+
+// onlyVersion returns r filtered to CIDRs of the IP version only, v4 or v6,
+// or r itself if only is "".
+func onlyVersion(r io.Reader, only string) io.Reader {
+	if only == "" {
+		return r
+	}
+	return &versionFilter{r: bufio.NewReader(r), only: only}
+}
+
+func (f *versionFilter) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		line, err := f.r.ReadString('\n')
+		if line == "" {
+			return 0, err
+		}
+		if !isVersion(listText(line), f.only) {
+			line = line[len(strings.TrimRight(line, "\r\n")):]
+		}
+		f.buf = []byte(line)
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+// isVersion reports whether cidr isn't a CIDR of the other IP version to
+// only, v4 or v6.
+func isVersion(cidr, only string) bool {
+	_, ipnet, err := parseCIDR(cidr)
+	if err != nil {
+		return true
+	}
+	return (len(ipnet.IP) == net.IPv6len) == (only == "v6")
+}
+
+// onlyVersionArgs returns args without the CIDRs of the other IP version to
+// only, as versionFilter does for lists.
+func onlyVersionArgs(args []string, only string) []string {
+	kept := []string{}
+	for _, arg := range args {
+		if isVersion(arg, only) {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// listMain runs fn reading from the --in file or stdin. Output is buffered
+// and only written to the --out file or stdout once fn succeeds, so a failed
+// run doesn't truncate the output, which may even be the input file.
+func listMain(o options, stdin io.Reader, stdout, stderr io.Writer, fn func(io.Reader, io.Writer) error) int {
+	in, err := openInput(o.in, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInputFile
+	}
+	prof := newProfiler(stderr, o.profile)
+	counter := &lineCounter{r: in}
+	var buf bytes.Buffer
+	err = fn(onlyVersion(counter, o.only), &buf)
+	in.Close()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	prof.mark("process")
+
+	if o.trim {
+		trimOutput(&buf)
+	}
+	if err := writeOutput(o.out, stdout, &buf); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitOutputFile
+	}
+	prof.mark("write")
+	prof.done(counter.lines)
+	return 0
+}
+
+// suspectTags are tags which usually mean a listed CIDR is a mistake.
+var suspectTags = []string{"multicast", "reserved", "unspecified"}
+
+// validateList writes each pair of listed CIDRs which overlap, returning an
+// error if there are any. CIDRs with suspectTags are warned about to
+// warnings, without failing.
+func validateList(w, warnings io.Writer, entries []listEntry) error {
+	nets, err := parseList(entries)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		r, _ := calc(e.cidr)
+		for _, tag := range r.Tags {
+			if tagMatches(tag, suspectTags) {
+				fmt.Fprintln(warnings, e.err(fmt.Errorf("warning: %s is %s", e.cidr, tag)))
+				break
+			}
+		}
+	}
+	overlaps := 0
+	for i := range nets {
+		for j := i + 1; j < len(nets); j++ {
+			if netsOverlap(nets[i], nets[j]) {
+				fmt.Fprintf(w, "line %d: %s overlaps line %d: %s\n", entries[i].line, entries[i].cidr, entries[j].line, entries[j].cidr)
+				overlaps++
+			}
+		}
+	}
+	if overlaps > 0 {
+		return fmt.Errorf("found %s", plural(overlaps, "overlap"))
+	}
+	return nil
+}
+
+// normalizeList copies a list from r to w with each CIDR rewritten in
+// canonical form, noting each changed line to notes.
+func normalizeList(r io.Reader, w, notes io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		cidr := text
+		if i := strings.Index(cidr, "#"); i >= 0 {
+			cidr = cidr[:i]
+		}
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			c, err := canonical(cidr)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			if c != cidr {
+				fmt.Fprintf(notes, "line %d: %s -> %s\n", line, cidr, c)
+				text = strings.Replace(text, cidr, c, 1)
+			}
+		}
+		fmt.Fprintln(w, text)
+	}
+	return scanner.Err()
+}
+
+// dedupe returns nets without repeats, keeping the first of each. Networks
+// are compared by value, so 2001:DB8::/32 and 2001:db8::1/32 are the same.
+func dedupe(nets []*net.IPNet) []*net.IPNet {
+	seen := map[string]bool{}
+	out := []*net.IPNet{}
+	for _, n := range nets {
+		if key := netKey(n); !seen[key] {
+			seen[key] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// openInput opens path for reading, or returns stdin when path is empty.
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == "" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
+
+// writeOutput writes buf to the file at path, or stdout if path is empty.
+func writeOutput(path string, stdout io.Writer, buf *bytes.Buffer) error {
+	out, err := createOutput(path, stdout)
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// trimOutput strips the trailing whitespace from buf, for --trim.
+func trimOutput(buf *bytes.Buffer) {
+	buf.Truncate(len(bytes.TrimRight(buf.Bytes(), " \t\r\n")))
+}
+
+// createOutput creates path for writing, or returns stdout when path is empty.
+func createOutput(path string, stdout io.Writer) (io.WriteCloser, error) {
+	if path == "" {
+		return nopWriteCloser{stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeList(t *testing.T) {
+	in := `# plan
+10.0.0.0/24
+10.0.1.7/24  # web
+  2001:DB8:0:0::1/32
+
+192.168.0.0/16
+`
+	expected := `# plan
+10.0.0.0/24
+10.0.1.0/24  # web
+  2001:db8::/32
+
+192.168.0.0/16
+`
+	var out, notes bytes.Buffer
+	if err := normalizeList(strings.NewReader(in), &out, &notes); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", out.String(), expected)
+	}
+	expectedNotes := "line 3: 10.0.1.7/24 -> 10.0.1.0/24\nline 4: 2001:DB8:0:0::1/32 -> 2001:db8::/32\n"
+	if notes.String() != expectedNotes {
+		t.Errorf("\ngot notes\n%s\nexpected\n%s", notes.String(), expectedNotes)
+	}
+
+	if err := normalizeList(strings.NewReader("10.0.0.0/24\nbogus\n"), &out, &notes); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected line 2 error, got %v", err)
+	}
+}
+
+func TestValidateList(t *testing.T) {
+	entries, err := readList(strings.NewReader(`# plan
+10.0.0.0/24   # web
+10.0.1.0/24   # db
+
+10.0.0.128/25 # oops
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = validateList(&buf, &buf, entries)
+	if err == nil || err.Error() != "found 1 overlap" {
+		t.Errorf("expected 1 overlap, got %v", err)
+	}
+	if expected := "line 2: 10.0.0.0/24 overlaps line 5: 10.0.0.128/25\n"; buf.String() != expected {
+		t.Errorf("\ngot      %q\nexpected %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := validateList(&buf, &buf, entries[:2]); err != nil || buf.Len() != 0 {
+		t.Errorf("expected no overlaps, got %v %q", err, buf.String())
+	}
+}
+
+func TestValidateListWarnings(t *testing.T) {
+	entries, err := readList(strings.NewReader("10.0.0.0/24\n224.1.1.0/24\n240.0.0.0/8\n0.0.0.0/32\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, warnings bytes.Buffer
+	if err := validateList(&out, &warnings, entries); err != nil {
+		t.Fatalf("expected warnings not to fail validation, got %v", err)
+	}
+	expected := "line 2: warning: 224.1.1.0/24 is multicast\n" +
+		"line 3: warning: 240.0.0.0/8 is reserved (RFC 1112)\n" +
+		"line 4: warning: 0.0.0.0/32 is unspecified\n"
+	if warnings.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", warnings.String(), expected)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no overlaps, got %q", out.String())
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	in := "2001:DB8::/32\n10.0.0.0/24\n2001:db8::/32\n2001:0db8:0000::1/32\n10.0.0.0/25\n::ffff:10.0.0.0/120\n10.0.0.0/24\n"
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--dedupe"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := "2001:db8::/32\n10.0.0.0/24\n10.0.0.0/25\n::ffff:10.0.0.0/120\n"
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+}
+
+func TestOnlyVersion(t *testing.T) {
+	in := "10.0.0.0/24\n2001:db8::/48\n"
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--only", "v6", "--json-lines"}, "2001:db8::"},
+		{[]string{"--only", "v4", "--json-lines"}, "10.0.0.0"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, strings.NewReader(in), &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d: %s", tt.args, code, stderr.String())
+		}
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], `"network":"`+tt.expected+`"`) {
+			t.Errorf("%v: expected only %s, got\n%s", tt.args, tt.expected, stdout.String())
+		}
+	}
+
+	// Line numbers in errors still count the dropped lines.
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--only", "v4", "--aggregate"}, strings.NewReader("2001:db8::/48\nbad\n"), &stdout, &stderr); code == 0 {
+		t.Error("expected the invalid line to fail")
+	}
+	if !strings.Contains(stderr.String(), "line 2:") {
+		t.Errorf("expected the error on line 2, got %q", stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--only", "v6", "--aggregate", "10.0.0.0/25", "2001:db8::/48", "10.0.0.128/25"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "2001:db8::/48\n" {
+		t.Errorf("expected only the IPv6 argument, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--only", "v4", "--aggregate", "--sorted-input"}, strings.NewReader("10.0.0.0/25\n2001:db8::/48\n10.0.0.128/25\n"), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/24\n" {
+		t.Errorf("expected only the IPv4 lines aggregated, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--only", "v6", "--stdin-json"}, strings.NewReader(`["10.0.0.0/24", "2001:db8::/64"]`), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "10.0.0.0") || !strings.Contains(stdout.String(), `"network": "2001:db8::"`) {
+		t.Errorf("expected only the IPv6 element, got\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--only", "v6", "10.0.0.0/24"}, nil, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("expected no report for an IPv4 CIDR, got %d %q", code, stdout.String())
+	}
+}
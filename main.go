@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"math/big"
+	"math/bits"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Result struct {
@@ -24,70 +29,995 @@ type Result struct {
 	Tags         []string
 }
 
+// FirstIP returns the first IP in the network, its network address.
+func (r Result) FirstIP() net.IP {
+	return r.Network
+}
+
+// LastIP returns the last IP in the network, which for IPv4 is its broadcast
+// address.
+func (r Result) LastIP() net.IP {
+	return r.Max
+}
+
+type options struct {
+	reportOptions
+	assertCount        string
+	json               bool
+	fields             string
+	jsonSchema         bool
+	completion         string
+	aggregate          bool
+	aggregateStats     bool
+	aggregateStrict    bool
+	sortedInput        bool
+	maxLen             int
+	mergeAdjacent      bool
+	hierarchy          bool
+	nearestAggregate   bool
+	normalize          bool
+	dedupe             bool
+	validateList       string
+	diff               bool
+	unmap              bool
+	usableTotal        bool
+	prefixDensity      bool
+	countDistinct      bool
+	aggregationSavings bool
+	between            bool
+	cidrMath           bool
+	largestBlockAt     string
+	fromWildcard       string
+	maxBits            int
+	summaryJSON        bool
+	countByPrefix      bool
+	stdinJSON          bool
+	jsonLines          bool
+	matchTags          stringsValue
+	repl               bool
+	follow             string
+	in                 string
+	only               string
+	out                string
+	trim               bool
+	timestamp          bool
+	profile            bool
+	bitAt              int
+	flipBit            int
+	vs                 string
+	delegate           string
+	alloc              stringsValue
+	parents            bool
+	times              string
+	intersect          string
+	split              string
+	halve              bool
+	randomizeSubnets   string
+	seed               int64
+	countSubnets       string
+	subnetOf           string
+	forIP              string
+	hosts              bool
+	zoneFile           bool
+	nameTemplate       string
+	maxOutput          int
+	plan               string
+	complement         bool
+	goLiteral          bool
+	base85             bool
+	fromBase85         string
+	shorten            bool
+	ipOnly             bool
+	gateway            string
+	reverseBits        bool
+	canonical          bool
+	ip6Arpa            bool
+	arpaZone           bool
+	netmaskInt         string
+	stripPort          bool
+	anonymize          bool
+	comparePrevious    string
+	cache              string
+	salt               string
+	colorMode          string
+	asciiOnly          bool
+}
+
+// followPoll is how often --follow checks for more lines.
+const followPoll = 250 * time.Millisecond
+
+const (
+	exitFailure    = 1
+	exitInputFile  = 2
+	exitOutputFile = 3
+)
+
+type reportOptions struct {
+	pad            string
+	versionPolicy  string
+	port           string
+	v6Form         string
+	maskFormat     string
+	explainMask    bool
+	maskOnes       bool
+	classful       bool
+	wildcardBinary bool
+	wildcardLen    bool
+	prettyIPv6     bool
+	nibbles        bool
+	teach          bool
+	byteOrder      bool
+	reservedIID    bool
+	usable         bool
+	reserveFront   int
+	reserveBack    int
+	fraction       bool
+	groupDigits    bool
+	humanCount     bool
+	digitSeparator string
+	color          bool
+	plainDivider   bool
+	now            func() time.Time // for --timestamp, nil for none
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		exitUsage()
+	args, err := envArgs(os.Args[1:], os.Getenv(argsEnv))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFailure)
+	}
+	os.Exit(run(args, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run is the whole command line program, returning its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var o options
+	flags := newFlagSet(&o)
+	flags.SetOutput(stderr)
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return usage(flags)
+	}
+
+	if o.only != "" {
+		given := len(args)
+		args = onlyVersionArgs(args, o.only)
+		if given > 0 && len(args) == 0 {
+			return 0 // nothing left to report on
+		}
+	}
+	if o.asciiOnly {
+		o.colorMode = "never"
+		o.plainDivider = true
+	}
+	if o.timestamp {
+		o.now = time.Now
+	}
+
+	switch {
+	case o.jsonSchema:
+		b, err := jsonSchema()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		fmt.Fprintf(stdout, "%s\n", b)
+		return 0
+	case o.repl:
+		return repl(stdin, stdout, stderr)
+	case o.follow != "":
+		f, err := newFollower(o.follow, followPoll)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitInputFile
+		}
+		defer f.Close()
+		o.color = useColor(o.colorMode, stdout)
+		if err := followLines(onlyVersion(f, o.only), stdout, stderr, o.reportOptions); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		return 0
+	case o.completion != "":
+		completion(stdout, flags, o.completion)
+		return 0
+	case o.fromBase85 != "":
+		ip, err := decodeBase85(o.fromBase85)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		fmt.Fprintln(stdout, ipString(ip))
+		return 0
+	case o.aggregate:
+		return aggregateMain(o, args, stdin, stdout, stderr)
+	case o.countDistinct:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, addressCount(aggregate(nets)))
+			return nil
+		})
+	case o.aggregationSavings:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, aggregationSavings(nets))
+			return nil
+		})
+	case o.cidrMath:
+		if len(args) == 0 {
+			return usage(flags)
+		}
+		if err := cidrMath(stdout, strings.Join(args, " ")); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		return 0
+	case o.between:
+		if len(args) != 2 {
+			return usage(flags)
+		}
+		count, err := countBetween(args[0], args[1])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		fmt.Fprintln(stdout, count)
+		return 0
+	case o.fromWildcard != "":
+		ones, err := wildcardPrefixLen(o.fromWildcard)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		fmt.Fprintf(stdout, "/%d\n", ones)
+		return 0
+	case o.largestBlockAt != "":
+		ipnet, err := largestBlockAt(o.largestBlockAt, o.maxBits)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		fmt.Fprintln(stdout, netString(ipnet))
+		return 0
+	case o.summaryJSON:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			return writeJSON(out, summarize(nets))
+		})
+	case o.countByPrefix:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			return countByPrefix(out, nets)
+		})
+	case len(o.matchTags) > 0:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			entries, err := listInput(args, in)
+			if err != nil {
+				return err
+			}
+			return matchTags(out, entries, o.matchTags)
+		})
+	case o.jsonLines:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			entries, err := listInput(args, in)
+			if err != nil {
+				return err
+			}
+			return jsonLines(out, entries)
+		})
+	case o.stdinJSON:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			return jsonList(in, out, o.only)
+		})
+	case o.nearestAggregate:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			super, err := supernet(nets)
+			if err != nil {
+				return err
+			}
+			covered, listed := addressCount([]*net.IPNet{super}), addressCount(aggregate(nets))
+			fmt.Fprintln(out, netString(super))
+			fmt.Fprintf(stderr, "%s covers %d addresses, %d more than the %d listed\n", netString(super), covered, new(big.Int).Sub(covered, listed), listed)
+			return nil
+		})
+	case o.mergeAdjacent:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			for _, n := range mergeAdjacent(nets) {
+				fmt.Fprintln(out, netString(n))
+			}
+			return nil
+		})
+	case o.validateList != "":
+		f, err := os.Open(o.validateList)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitInputFile
+		}
+		defer f.Close()
+		entries, err := readList(onlyVersion(f, o.only))
+		if err == nil {
+			err = validateList(stdout, stderr, entries)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		return 0
+	case o.diff:
+		if len(args) != 2 {
+			return usage(flags)
+		}
+		lists := make([][]listEntry, 2)
+		for i, path := range args {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitInputFile
+			}
+			lists[i], err = readList(onlyVersion(f, o.only))
+			f.Close()
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitInputFile
+			}
+		}
+		if err := diffLists(stdout, lists[0], lists[1], o.unmap); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		return 0
+	case o.hierarchy:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			hierarchy(out, nets)
+			return nil
+		})
+	case o.dedupe:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			nets, err := listNets(args, in)
+			if err != nil {
+				return err
+			}
+			for _, n := range dedupe(nets) {
+				fmt.Fprintln(out, netString(n))
+			}
+			return nil
+		})
+	case o.normalize:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			return normalizeList(in, out, stderr)
+		})
+	case o.prefixDensity:
+		if len(args) == 0 {
+			return usage(flags)
+		}
+		_, parent, err := parseCIDR(args[0])
+		if err != nil {
+			return usage(flags)
+		}
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			allocs, err := listNets(args[1:], in)
+			if err != nil {
+				return err
+			}
+			return prefixDensity(out, parent, allocs)
+		})
+	case o.usableTotal:
+		return listMain(o, stdin, stdout, stderr, func(in io.Reader, out io.Writer) error {
+			entries, err := listInput(args, in)
+			if err != nil {
+				return err
+			}
+			return countUsableTotal(out, entries)
+		})
+	}
+
+	if len(args) != 1 {
+		return usage(flags)
+	}
+	cidr := args[0]
+	if o.stripPort {
+		if cidr, o.port, err = splitPort(cidr); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+	}
+	if o.netmaskInt != "" {
+		if cidr, err = netmaskIntCIDR(cidr, o.netmaskInt); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+	}
+	prof := newProfiler(stderr, o.profile)
+	ip, ipnet, err := parseCIDR(cidr)
+	if _, ok := err.(*net.ParseError); ok {
+		return usage(flags)
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	prof.mark("parse")
+	ip, ipnet = applyVersionPolicy(ip, ipnet, o.versionPolicy)
+	r := newResult(ip, ipnet)
+	prof.mark("calc")
+	if o.anonymize {
+		cidr = anonymize(r, o.salt)
+		ipnet, r, _ = parseWithPolicy(cidr, o.versionPolicy)
+	}
+	if o.comparePrevious != "" {
+		if o.cache == "" {
+			o.cache = defaultCachePath()
+		}
+		previous, err := comparePrevious(o.cache, o.comparePrevious, ipnet.String())
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		if previous != "" && previous != ipnet.String() {
+			fmt.Fprintf(stderr, "%s changed from %s to %s\n", o.comparePrevious, previous, ipnet)
+		}
+	}
+	// Like list output, --out is only written once the output is complete.
+	var buf bytes.Buffer
+	out := stdout
+	if o.out != "" {
+		stdout = &buf
+	}
+	o.color = useColor(o.colorMode, stdout)
+	if o.trim {
+		stdout = &buf
+	}
+
+	switch {
+	case o.assertCount != "":
+		err = assertIPCount(r, o.assertCount)
+	case o.bitAt >= 0:
+		var bit uint
+		if bit, err = bitAt(r.IP, o.bitAt); err == nil {
+			fmt.Fprintln(stdout, bit)
+		}
+	case o.flipBit >= 0:
+		err = writeFlipBit(stdout, r, o.flipBit)
+	case o.vs != "":
+		err = writeCompareBinary(stdout, r, o.vs)
+	case o.delegate != "":
+		err = delegate(stdout, ipnet, o.delegate)
+	case len(o.alloc) > 0:
+		err = writeAllocation(stdout, ipnet, o.alloc)
+	case o.times != "":
+		var other Result
+		if other, err = calc(o.times); err == nil {
+			fmt.Fprintln(stdout, times(r, other))
+		}
+	case o.intersect != "":
+		var other *net.IPNet
+		if _, other, err = parseCIDR(o.intersect); err == nil {
+			err = writeIntersection(stdout, ipnet, other)
+		}
+	case o.parents:
+		err = parents(stdout, ipnet)
+	case o.subnetOf != "":
+		var sub *net.IPNet
+		if sub, err = subnetFor(ipnet, o.subnetOf, o.forIP); err == nil {
+			fmt.Fprintln(stdout, netString(sub))
+		}
+	case o.countSubnets != "":
+		err = countSubnets(stdout, ipnet, o.countSubnets)
+	case o.halve:
+		err = halve(stdout, ipnet)
+	case o.split != "":
+		err = split(stdout, ipnet, o.split, o.maxOutput)
+	case o.randomizeSubnets != "":
+		err = randomizeSubnets(stdout, ipnet, o.randomizeSubnets, o.seed, o.maxOutput)
+	case o.hosts:
+		err = hosts(stdout, r, o.maxOutput)
+	case o.zoneFile:
+		err = zoneFile(stdout, r, o.nameTemplate, o.maxOutput)
+	case o.complement:
+		_, bits := ipnet.Mask.Size()
+		root := &net.IPNet{IP: make(net.IP, len(ipnet.IP)), Mask: net.CIDRMask(0, bits)}
+		for _, n := range exclude(root, ipnet) {
+			fmt.Fprintln(stdout, netString(n))
+		}
+	case o.reverseBits:
+		writeReverseBits(stdout, r.IP)
+	case o.ipOnly:
+		fmt.Fprintln(stdout, ipString(r.IP))
+	case o.gateway != "":
+		var gw net.IP
+		if gw, err = gateway(r, o.gateway); err == nil {
+			fmt.Fprintln(stdout, ipString(gw))
+		}
+	case o.canonical:
+		fmt.Fprintln(stdout, netString(ipnet))
+	case o.shorten:
+		var short string
+		var ok bool
+		if short, ok, err = shorten(cidr); err == nil {
+			if !ok {
+				fmt.Fprintf(stderr, "%s is not in RFC 5952 form\n", cidr)
+			}
+			fmt.Fprintln(stdout, short)
+		}
+	case o.base85:
+		var encoded string
+		if encoded, err = encodeBase85(r.IP); err == nil {
+			fmt.Fprintln(stdout, encoded)
+		}
+	case o.goLiteral:
+		fmt.Fprintln(stdout, goLiteral(ipnet))
+	case o.arpaZone:
+		err = arpaZoneFile(stdout, r, o.nameTemplate, o.maxOutput)
+	case o.ip6Arpa:
+		var zone string
+		if zone, err = ip6ArpaZone(ipnet); err == nil {
+			fmt.Fprintln(stdout, zone)
+		}
+	case o.plan != "":
+		var lo, hi int
+		if lo, hi, err = parsePrefixRange(o.plan); err == nil {
+			err = plan(stdout, ipnet, lo, hi, o.maxOutput)
+		}
+	case o.fields != "":
+		var fields map[string]json.RawMessage
+		if fields, err = selectFields(r, o.fields); err == nil {
+			err = writeJSON(stdout, fields)
+		}
+	case o.json:
+		err = writeJSON(stdout, r)
+	default:
+		err = report(stdout, cidr, o.reportOptions)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	prof.mark("format")
+	if o.out != "" || o.trim {
+		if o.trim {
+			trimOutput(&buf)
+		}
+		if err := writeOutput(o.out, out, &buf); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitOutputFile
+		}
+	}
+	prof.done(0)
+	return 0
+}
+
+func newFlagSet(o *options) *flag.FlagSet {
+	flags := flag.NewFlagSet("cidrinfo", flag.ContinueOnError)
+	flags.Usage = func() {}
+	flags.StringVar(&o.assertCount, "assert-count", "", "exit non-zero unless the CIDR contains exactly `N` IPs")
+	flags.BoolVar(&o.json, "json", false, "print the result as JSON")
+	flags.StringVar(&o.fields, "fields", "", "print only these comma separated `fields` of the JSON result e.g. network,broadcast,ipCount")
+	flags.BoolVar(&o.jsonSchema, "json-schema", false, "print the JSON Schema describing --json output")
+	flags.BoolVar(&o.aggregate, "aggregate", false, "print the smallest set of CIDRs covering those given as arguments or listed one per line on stdin")
+	flags.BoolVar(&o.aggregateStrict, "aggregate-strict", false, "with --aggregate, check the result covers exactly the input's addresses, failing if not")
+	flags.BoolVar(&o.aggregateStats, "aggregate-stats", false, "with --aggregate, summarize the reduction on stderr")
+	flags.IntVar(&o.maxLen, "max-len", 0, "with --aggregate, split any CIDR shorter than /`N` into /N subnets")
+	flags.BoolVar(&o.sortedInput, "sorted-input", false, "with --aggregate, merge in one pass without holding the list in memory, assuming it is sorted e.g. by a previous --aggregate")
+	flags.BoolVar(&o.nearestAggregate, "nearest-aggregate", false, "print the smallest single CIDR covering all those given, noting on stderr how many extra addresses it covers")
+	flags.BoolVar(&o.mergeAdjacent, "merge-adjacent", false, "like --aggregate but only merge pairs of listed sibling prefixes, in one pass, keeping any nested prefixes")
+	flags.StringVar(&o.validateList, "validate-list", "", "report overlapping CIDRs listed in `file`, exiting non-zero if there are any, and warn about multicast, reserved or unspecified ones")
+	flags.BoolVar(&o.diff, "diff-cidrs", false, "compare the CIDRs listed in two files given as arguments, marking those removed with - and added with +")
+	flags.BoolVar(&o.unmap, "unmap", false, "with --diff-cidrs, match IPv4-mapped IPv6 prefixes like ::ffff:10.0.0.0/120 to their IPv4 equivalent")
+	flags.BoolVar(&o.hierarchy, "hierarchy", false, "print the CIDRs given as arguments or listed on stdin sorted, each indented under the listed CIDRs covering it")
+	flags.BoolVar(&o.dedupe, "dedupe", false, "print the CIDRs given as arguments or listed on stdin once each, in canonical form, comparing their parsed values")
+	flags.BoolVar(&o.normalize, "normalize-list", false, "rewrite each CIDR listed on stdin in canonical form, keeping comments and noting changed lines on stderr")
+	flags.BoolVar(&o.usableTotal, "count-usable-total", false, "print the total usable host IPs across the CIDRs given as arguments or listed on stdin")
+	flags.BoolVar(&o.prefixDensity, "prefix-density", false, "print how much of the CIDR is used by the allocated CIDRs given as further arguments or listed on stdin, and its largest free block")
+	flags.Var(newChoiceValue(&o.completion, "", "bash", "zsh", "fish"), "completion", "print a completion script for `shell`, one of bash, zsh or fish")
+	flags.StringVar(&o.follow, "follow", "", "report on each CIDR as it's appended to `file`, like tail -f, until interrupted")
+	flags.BoolVar(&o.repl, "repl", false, "read CIDRs and commands like \"split /26\" from stdin, reporting on each line until EOF")
+	flags.BoolVar(&o.countDistinct, "count-distinct", false, "print how many distinct IPs the CIDRs given as arguments or listed on stdin cover, counting overlaps once")
+	flags.BoolVar(&o.aggregationSavings, "aggregation-savings", false, "print how many route table entries aggregating the CIDRs given as arguments or listed on stdin saves")
+	flags.BoolVar(&o.cidrMath, "cidr-math", false, "evaluate the arguments as one of: CIDR + N or CIDR - N (move by N IPs), CIDR & CIDR (intersection), CIDR - CIDR (exclusion), /N - /M (prefix length difference)")
+	flags.BoolVar(&o.between, "between", false, "print how many IPs there are from the first IP argument to the second, inclusive")
+	flags.StringVar(&o.fromWildcard, "from-wildcard", "", "print the prefix length for an IPv4 ACL wildcard `mask` like 0.0.3.255")
+	flags.StringVar(&o.largestBlockAt, "largest-block-at", "", "print the largest aligned CIDR starting at `IP`")
+	flags.IntVar(&o.maxBits, "max-bits", -1, "with --largest-block-at, allow at most `N` host bits")
+	flags.BoolVar(&o.countByPrefix, "count-by-prefix", false, "print how many of the CIDRs given as arguments or listed on stdin there are of each prefix length, and their total IPs")
+	flags.BoolVar(&o.summaryJSON, "summary-json", false, "print a JSON summary of the CIDRs given as arguments or listed on stdin")
+	flags.Var(&o.matchTags, "match-tag", "print only the CIDRs given as arguments or listed on stdin with the `tag`, which may be repeated to match any of several")
+	flags.BoolVar(&o.jsonLines, "json-lines", false, "print the JSON result for each CIDR given as an argument or listed on stdin, one per line")
+	flags.BoolVar(&o.stdinJSON, "stdin-json", false, "read a JSON array of CIDR strings from stdin, printing a JSON array of their results")
+	flags.StringVar(&o.comparePrevious, "compare-to-previous", "", "remember the CIDR's network under `label`, noting on stderr if it changed since the last run")
+	flags.StringVar(&o.cache, "cache", "", "with --compare-to-previous, keep prefixes in JSON `file` instead of under the user cache directory")
+	flags.BoolVar(&o.profile, "profile", false, "print how long each stage took to stderr")
+	flags.Var(newChoiceValue(&o.only, "", "v4", "v6"), "only", "ignore CIDRs which aren't IP `version` v4 or v6, in lists and arguments")
+	flags.StringVar(&o.in, "in", "", "read lists from `file` instead of stdin")
+	flags.StringVar(&o.out, "out", "", "write output to `file` instead of stdout")
+	flags.BoolVar(&o.timestamp, "timestamp", false, "start the report with the current time in UTC, in ISO 8601 format")
+	flags.BoolVar(&o.trim, "trim", false, "strip trailing blank lines and whitespace from the output")
+	flags.IntVar(&o.bitAt, "bit-at", -1, "print the value of bit `N` of the IP address, counting from 0 at the left of its binary form")
+	flags.StringVar(&o.vs, "vs", "", "show the binary IP address above that of `ip2`, marking the bits which differ, and whether ip2 is in the network")
+	flags.IntVar(&o.flipBit, "flip-bit", -1, "show the IP address with bit `N` toggled, counting as --bit-at does, and whether it stays in the network")
+	flags.StringVar(&o.delegate, "delegate", "", "count and list the IPv6 `/prefix` delegations that fit in the CIDR")
+	flags.Var(&o.alloc, "alloc", "allocate a subnet with prefix length `/N` from the CIDR, first fit, printing it and then the free space left; repeat for each subnet in turn")
+	flags.StringVar(&o.times, "times", "", "print how many times more IPs the CIDR holds than `CIDR` does")
+	flags.StringVar(&o.intersect, "intersect", "", "print the part of the CIDR also in `cidr2`, or \"no overlap\"")
+	flags.BoolVar(&o.parents, "parents", false, "list each network containing the CIDR, up to /0")
+	flags.StringVar(&o.countSubnets, "count-subnets", "", "print how many `/prefix` subnets fit in the CIDR, without listing them")
+	flags.StringVar(&o.subnetOf, "subnet-of", "", "print which subnet with prefix length `/N` of the CIDR holds the --for IP")
+	flags.StringVar(&o.forIP, "for", "", "the `IP` for --subnet-of")
+	flags.StringVar(&o.split, "split", "", "list the `/prefix` subnets of the CIDR")
+	flags.BoolVar(&o.halve, "halve", false, "print the two halves of the CIDR, the subnets one bit longer")
+	flags.StringVar(&o.randomizeSubnets, "randomize-subnets", "", "list random sized subnets no smaller than `/prefix` which exactly cover the CIDR, in random order")
+	flags.Int64Var(&o.seed, "seed", -1, "with --randomize-subnets, seed the random choices with `N` for reproducible output")
+	flags.BoolVar(&o.hosts, "hosts", false, "list the usable host IPs of the CIDR")
+	flags.BoolVar(&o.zoneFile, "zone-file", false, "print a placeholder A or AAAA record for each usable host IP")
+	flags.StringVar(&o.nameTemplate, "name-template", "host-{ip}", "with --zone-file or --arpa-zone, name hosts by `template`, where {ip} is the IP with - between its fields")
+	flags.IntVar(&o.maxOutput, "max-output", 65536, "refuse to print more than `N` lines for --split, --hosts, --zone-file, --arpa-zone, --randomize-subnets, --plan or --aggregate --max-len, or 0 for no limit")
+	flags.StringVar(&o.plan, "plan", "", "tabulate subnet counts and usable hosts for a `/prefix` or range of them e.g. /24-/28")
+	flags.BoolVar(&o.complement, "complement", false, "list the CIDRs covering every address of the same version except the CIDR's, at most one per prefix length")
+	flags.BoolVar(&o.reverseBits, "reverse-bits", false, "print the IP address with the bits of each byte reversed, in binary alongside the original")
+	flags.Var(newChoiceValue(&o.gateway, "", "first", "last"), "gateway", "print the conventional gateway address, the first or last usable IP")
+	flags.BoolVar(&o.ipOnly, "ip-only", false, "print just the IP address, in standard form with host bits intact")
+	flags.BoolVar(&o.canonical, "canonical", false, "print the CIDR in standard form with host bits cleared")
+	flags.BoolVar(&o.shorten, "shorten", false, "print the CIDR with its address in the shortest RFC 5952 form, noting on stderr if it wasn't given that way")
+	flags.BoolVar(&o.base85, "base85", false, "print the IPv6 address in the compact RFC 1924 base 85 encoding")
+	flags.StringVar(&o.fromBase85, "from-base85", "", "print the IPv6 address given in RFC 1924 base 85 `encoding`")
+	flags.BoolVar(&o.goLiteral, "go-literal", false, "print the network as a Go *net.IPNet literal")
+	flags.BoolVar(&o.arpaZone, "arpa-zone", false, "print a reverse DNS zone file skeleton for an IPv4 network, with a PTR record for each usable host named as for --zone-file")
+	flags.BoolVar(&o.ip6Arpa, "ip6-arpa", false, "print the ip6.arpa reverse DNS zone for the nibble aligned IPv6 network")
+	flags.BoolVar(&o.stripPort, "strip-port", false, "accept a :port suffix, like 10.0.0.0/24:8080 or [2001:db8::]/48:443, reporting the port separately")
+	flags.StringVar(&o.netmaskInt, "netmask-int", "", "give the IPv4 mask as a 32 bit `integer` and the argument as a plain IP e.g. 10.0.0.0 --netmask-int 4294966272")
+	flags.BoolVar(&o.anonymize, "anonymize", false, "replace the network bits with a hash, keeping the version, prefix length and host bits, so output can be shared")
+	flags.StringVar(&o.salt, "salt", "", "salt for --anonymize, which otherwise gives the same result for the same CIDR everywhere")
+	flags.Var(newChoiceValue(&o.colorMode, "auto", "auto", "always", "never"), "color", "color tags by severity: always, never, or auto when stdout is a terminal and NO_COLOR is unset")
+	flags.Var(newChoiceValue(&o.pad, "fixed", "fixed", "fit"), "pad", "address column width: fixed to the widest possible address, or fit to those printed")
+	flags.BoolVar(&o.plainDivider, "plain-divider", false, "draw bit ranges like [  22  ] instead of |-- 22 --|, keeping the same width")
+	flags.BoolVar(&o.asciiOnly, "ascii-only", false, "only print plain characters: implies --plain-divider and --color=never")
+	flags.BoolVar(&o.usable, "usable", false, "show the range of usable host IPs")
+	flags.IntVar(&o.reserveFront, "reserve-front", 0, "exclude the first `N` usable IPs, e.g. for a gateway, from the usable range")
+	flags.IntVar(&o.reserveBack, "reserve-back", 0, "exclude the last `N` usable IPs from the usable range")
+	flags.BoolVar(&o.humanCount, "human-count", false, "also give IP counts approximately in words like ~18.4 quintillion, grouping their digits")
+	flags.BoolVar(&o.groupDigits, "group-digits", false, "separate thousands in IP counts e.g. 16,777,216")
+	flags.StringVar(&o.digitSeparator, "digit-separator", ",", "thousands `separator` for --group-digits")
+	flags.BoolVar(&o.fraction, "fraction", false, "show what fraction of all IPv4 or IPv6 addresses the CIDR covers")
+	flags.BoolVar(&o.reservedIID, "reserved-iid", false, "for IPv6 /64 and shorter, say whether the IP address's interface identifier is one of those reserved by RFC 5453")
+	flags.BoolVar(&o.byteOrder, "byte-order", false, "show the IP address bytes in network byte order and reversed in little-endian host order, with their integer values")
+	flags.BoolVar(&o.teach, "teach", false, "annotate the binary IP address with which bits are network or host bits and their decimal values")
+	flags.BoolVar(&o.nibbles, "coalesce-v6-nibbles", false, "show an IPv6 mask as hex nibbles, marking each as network, host or split bits")
+	flags.BoolVar(&o.prettyIPv6, "pretty-ipv6", false, "show an IPv6 address as its 8 numbered hextets, marking the network/host boundary")
+	flags.BoolVar(&o.wildcardLen, "wildcard-length", false, "give the host bit count as the length of the ACL wildcard")
+	flags.BoolVar(&o.wildcardBinary, "show-wildcard-binary", false, "show the IPv4 host mask as an ACL wildcard mask, in binary")
+	flags.BoolVar(&o.classful, "classful-mask", false, "compare an IPv4 prefix to the default mask for its address class")
+	flags.BoolVar(&o.maskOnes, "count-leading-ones", false, "describe the mask as its count of leading ones followed by zeros")
+	flags.BoolVar(&o.explainMask, "explain-mask", false, "explain how the mask splits the octet containing the prefix boundary")
+	flags.Var(newChoiceValue(&o.v6Form, "compressed", "compressed", "expanded"), "v6-form", "IPv6 addresses in the report: compressed like 2001:db8::1, or expanded to all 8 groups of 4 digits for alignment")
+	flags.Var(newChoiceValue(&o.versionPolicy, mappedAsV4, mappedAsV4, strictV6), "version-policy", "IPv4-mapped IPv6 addresses like ::ffff:1.2.3.4: mapped-as-v4 treats a /96 or longer as IPv4, strict-v6 keeps them IPv6")
+	flags.Var(newChoiceValue(&o.maskFormat, "dotted", "dotted", "prefix", "hex"), "mask-format", "mask display: dotted (255.255.252.0), prefix (/22, host mask ~/22) or hex (0xfffffc00)")
+	return flags
+}
+
+// choiceValue is a flag.Value which only accepts one of a fixed set of strings.
+type choiceValue struct {
+	p       *string
+	choices []string
+}
+
+func newChoiceValue(p *string, value string, choices ...string) *choiceValue {
+	*p = value
+	return &choiceValue{p, choices}
+}
+
+func (c *choiceValue) String() string {
+	if c.p == nil {
+		return ""
+	}
+	return *c.p
+}
+
+func (c *choiceValue) Set(s string) error {
+	for _, choice := range c.choices {
+		if s == choice {
+			*c.p = s
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(c.choices, "|"))
+}
+
+// stringsValue is a flag.Value collecting each use of a repeatable flag.
+type stringsValue []string
+
+func (s *stringsValue) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsValue) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// parseArgs parses flags appearing anywhere in args, e.g. both
+// "--assert-count 256 10.0.0.0/24" and "10.0.0.0/24 --assert-count 256",
+// returning the remaining positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// usage prints the usage message to the flag set's output, returning the
+// exit status for a usage error.
+func usage(flags *flag.FlagSet) int {
+	fmt.Fprintln(flags.Output(), "specify a CIDR e.g. 10.20.30.40/22")
+	fmt.Fprintln(flags.Output())
+	flags.PrintDefaults()
+	return exitFailure
+}
+
+// ip formats an IP address, writing IPv6 addresses in full with --v6-form
+// expanded.
+func (ro reportOptions) ip(ip net.IP) string {
+	if ro.v6Form == "expanded" && len(ip) == net.IPv6len {
+		return expandIPv6(ip)
 	}
-	if err := report(os.Stdout, os.Args[1]); err != nil {
-		exitUsage()
+	return ipString(ip)
+}
+
+// mask formats an IPv6 mask in address form, in hex even where it looks
+// like an IPv4-mapped address, like the /80 host mask ::ffff:ffff:ffff.
+func (ro reportOptions) mask(m net.IPMask) string {
+	if ro.v6Form == "expanded" {
+		return expandIPv6(net.IP(m))
 	}
+	return compressIPv6(net.IP(m))
 }
 
-func exitUsage() {
-	fmt.Fprintln(os.Stderr, "specify a CIDR e.g. 10.20.30.40/22")
-	os.Exit(1)
+// count formats an IP count, grouping its digits if requested.
+func (ro reportOptions) count(n *big.Int) string {
+	if ro.groupDigits || ro.humanCount {
+		return groupDigits(n.String(), ro.digitSeparator)
+	}
+	return n.String()
 }
 
-func report(out io.Writer, cidr string) error {
+// countNames are the short scale names of powers of 1000, from 10^3.
+var countNames = []string{"thousand", "million", "billion", "trillion", "quadrillion", "quintillion", "sextillion", "septillion", "octillion", "nonillion", "decillion"}
+
+// approximateCount returns n to 3 significant figures in words like
+// ~18.4 quintillion, or as a power of ten beyond the named magnitudes, or ""
+// for counts under a thousand.
+func approximateCount(n *big.Int) string {
+	exp := len(n.String()) - 1
+	if exp < 3 {
+		return ""
+	}
+	f := new(big.Float).SetInt(n)
+	if named := exp / 3; named <= len(countNames) {
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(3*named)), nil))
+		return "~" + f.Quo(f, scale).Text('g', 3) + " " + countNames[named-1]
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	return "~" + f.Quo(f, scale).Text('g', 2) + " x 10^" + strconv.Itoa(exp)
+}
+
+func report(out io.Writer, cidr string, ro reportOptions) error {
 	p := func(format string, args ...interface{}) { fmt.Fprintf(out, format, args...) }
 	nl := func() { out.Write([]byte("\n")) }
 
-	r, err := calc(cidr)
+	_, r, err := parseWithPolicy(cidr, ro.versionPolicy)
 	if err != nil {
 		return err
 	}
 
-	var ipWidth string
+	var width int
 	var ipVer string
 	if r.IsV6 {
-		ipWidth = strconv.Itoa(39) // ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff
+		width = 39 // ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff
 		ipVer = "IPv6"
 	} else {
-		ipWidth = strconv.Itoa(15) // 255.255.255.255
+		width = 15 // 255.255.255.255
 		ipVer = "IPv4"
 	}
 
+	ipBits := fmt.Sprintf("%d (%s)", r.IPBits, ipVer)
+	netBits := fmt.Sprintf("%d (..../%d)", r.NetMaskSize, r.NetMaskSize)
+	hostBits := fmt.Sprintf("%d (%d - %d)", r.HostMaskSize, r.IPBits, r.NetMaskSize)
+	netMask := formatMask(r.NetMask, ro.maskFormat, false)
+	hostMask := formatMask(r.HostMask, ro.maskFormat, true)
+	if r.IsV6 && (ro.maskFormat == "dotted" || ro.maskFormat == "") {
+		netMask, hostMask = ro.mask(r.NetMask), ro.mask(r.HostMask)
+	}
+
+	var usableFirst, usableLast net.IP
+	var usableCount *big.Int
+	showUsable := ro.usable || ro.reserveFront > 0 || ro.reserveBack > 0
+	if showUsable {
+		if usableFirst, usableLast, usableCount, err = reservedRange(r, ro.reserveFront, ro.reserveBack); err != nil {
+			return err
+		}
+	}
+
+	if ro.pad == "fit" {
+		column := []string{
+			ipBits, ro.ip(r.IP),
+			netBits, netMask,
+			hostBits, hostMask,
+			ro.ip(r.Network), ro.ip(r.Max),
+		}
+		if showUsable {
+			column = append(column, ro.ip(usableFirst), ro.ip(usableLast))
+		}
+		width = 0
+		for _, s := range column {
+			if len(s) > width {
+				width = len(s)
+			}
+		}
+	}
+	ipWidth := strconv.Itoa(width)
+
 	hostMaskOffset := strings.Repeat(" ", r.NetMaskSize+r.NetMaskSize/8)
+	divider := maskLine
+	if ro.plainDivider {
+		divider = plainMaskLine
+	}
 
 	nl()
+	if ro.now != nil {
+		p("     Timestamp:  %s\n", ro.now().UTC().Format(time.RFC3339))
+	}
 	p("          CIDR:  %s\n", cidr)
+	if ro.port != "" {
+		p("          Port:  %s\n", ro.port)
+	}
 	if len(r.Tags) > 0 {
-		p("          Type:  %s\n", strings.Join(r.Tags, ", "))
+		tags := r.Tags
+		if ro.color {
+			tags = make([]string, len(r.Tags))
+			for i, tag := range r.Tags {
+				tags[i] = colorTag(tag)
+			}
+		}
+		p("          Type:  %s\n", strings.Join(tags, ", "))
+	}
+	if ipv4 := embeddedIPv4(r.IP); ipv4 != nil {
+		p(" Embedded IPv4:  %s\n", ipv4)
 	}
 	nl()
-	p("       IP bits:  %-"+ipWidth+"s  %s\n", fmt.Sprintf("%d (%s)", r.IPBits, ipVer), maskLine(r.IPBits))
-	p("    IP address:  %-"+ipWidth+"s  %s\n", r.IP, bin(r.IP))
+	p("       IP bits:  %-"+ipWidth+"s  %s\n", ipBits, divider(r.IPBits))
+	p("    IP address:  %-"+ipWidth+"s  %s\n", ro.ip(r.IP), bin(r.IP))
+	if ro.teach {
+		kinds, values := teachLines(r)
+		p("                 %-"+ipWidth+"s  %s\n", "", kinds)
+		p("                 %-"+ipWidth+"s  %s\n", "", values)
+	}
+	if ro.reservedIID && r.IsV6 && r.NetMaskSize <= 64 {
+		p("  Interface ID:  %s\n", iidNote(r.IP))
+	}
+	if ro.byteOrder {
+		network, host := byteOrders(r.IP)
+		p(" Network order:  %s\n", network)
+		p("    Host order:  %s on little-endian hosts\n", host)
+	}
+	if ro.prettyIPv6 && r.IsV6 {
+		hextets, labels := hextetView(r)
+		p("       Hextets:  %s\n", hextets)
+		p("                 %s\n", labels)
+	}
 	nl()
-	p("  Network bits:  %-"+ipWidth+"s  %s\n", fmt.Sprintf("%d (..../%d)", r.NetMaskSize, r.NetMaskSize), maskLine(r.NetMaskSize))
-	p("  Network mask:  %-"+ipWidth+"s  %s\n", net.IP(r.NetMask), bin(net.IP(r.NetMask)))
+	p("  Network bits:  %-"+ipWidth+"s  %s\n", netBits, divider(r.NetMaskSize))
+	p("  Network mask:  %-"+ipWidth+"s  %s\n", netMask, bin(net.IP(r.NetMask)))
+	if ro.nibbles && r.IsV6 {
+		mask, kinds, summary := nibbleView(r)
+		p("       Nibbles:  %s\n", mask)
+		p("                 %s\n", kinds)
+		p("                 %s\n", summary)
+	}
 	nl()
-	p("     Host bits:  %-"+ipWidth+"s  %s%s\n", fmt.Sprintf("%d (%d - %d)", r.HostMaskSize, r.IPBits, r.NetMaskSize), hostMaskOffset, maskLine(r.HostMaskSize))
-	p("     Host mask:  %-"+ipWidth+"s  %s\n", net.IP(r.HostMask), bin(net.IP(r.HostMask)))
+	p("     Host bits:  %-"+ipWidth+"s  %s%s\n", hostBits, hostMaskOffset, divider(r.HostMaskSize))
+	p("     Host mask:  %-"+ipWidth+"s  %s\n", hostMask, bin(net.IP(r.HostMask)))
+	if ro.wildcardBinary && !r.IsV6 {
+		p("  ACL wildcard:  %-"+ipWidth+"s  %s\n", net.IP(r.HostMask), strings.Join(binaryOctets(net.IP(r.HostMask)), " "))
+	}
+	if ro.wildcardLen {
+		p(" Wildcard bits:  %d-bit wildcard for /%d (%s)\n", r.HostMaskSize, r.NetMaskSize, maskString(r.HostMask))
+	}
+	if ro.classful && !r.IsV6 {
+		p(" Classful mask:  %s\n", classfulMask(r))
+	}
+	if ro.maskOnes {
+		p("  Mask pattern:  %s\n", maskPattern(r))
+	}
+	if ro.explainMask {
+		p("    Mask split:  %s\n", maskSplit(r))
+	}
 	nl()
-	p(" Number of IPs:  %s\n", fmt.Sprintf("%d (2 ^ %d)", r.IPCount, r.HostMaskSize))
-	p("      First IP:  %-"+ipWidth+"s  %s\n", r.Network, bin(r.Network))
-	p("       Last IP:  %-"+ipWidth+"s  %s\n", r.Max, bin(r.Max))
+	if approx := approximateCount(r.IPCount); ro.humanCount && approx != "" {
+		p(" Number of IPs:  %s (2 ^ %d, %s)\n", ro.count(r.IPCount), r.HostMaskSize, approx)
+	} else {
+		p(" Number of IPs:  %s (2 ^ %d)\n", ro.count(r.IPCount), r.HostMaskSize)
+	}
+	if ro.fraction {
+		p("      Fraction:  %s of %s (%s%%)\n", addressFraction(r).RatString(), ipVer, percent(addressFraction(r)))
+	}
+	p("      First IP:  %-"+ipWidth+"s  %s\n", ro.ip(r.Network), bin(r.Network))
+	p("       Last IP:  %-"+ipWidth+"s  %s\n", ro.ip(r.Max), bin(r.Max))
+	if r.IsV6 && r.NetMaskSize < 127 {
+		// IPv6 has no broadcast, but the all-zeros host address is the
+		// subnet-router anycast address (RFC 4291 2.6.1).
+		p("Router anycast:  %s\n", ro.ip(r.Network))
+		p("    Highest IP:  %s (all host bits set)\n", ro.ip(r.Max))
+	}
+	if showUsable {
+		nl()
+		if reserved := ro.reserveFront + ro.reserveBack; reserved > 0 {
+			p("    Usable IPs:  %s (%d reserved)\n", ro.count(usableCount), reserved)
+		} else {
+			p("    Usable IPs:  %s\n", ro.count(usableCount))
+		}
+		p("  First usable:  %-"+ipWidth+"s  %s\n", ro.ip(usableFirst), bin(usableFirst))
+		p("   Last usable:  %-"+ipWidth+"s  %s\n", ro.ip(usableLast), bin(usableLast))
+	}
 	nl()
 	return nil
 }
 
-func calc(cidr string) (Result, error) {
-	ip, ipnet, err := net.ParseCIDR(cidr)
+// Parse parses cidr like net.ParseCIDR, also returning the Result describing
+// it. Inverse notation like 10.0.0.0\10, counting host bits, is accepted too.
+// IPv4-mapped IPv6 networks are treated as IPv4, see mappedAsV4.
+func Parse(cidr string) (*net.IPNet, Result, error) {
+	return parseWithPolicy(cidr, mappedAsV4)
+}
+
+func parseWithPolicy(cidr, policy string) (*net.IPNet, Result, error) {
+	ip, ipnet, err := parseCIDR(cidr)
 	if err != nil {
-		return Result{}, err
+		return nil, Result{}, err
 	}
-	if ipv4 := ip.To4(); ipv4 != nil {
-		ip = ipv4 // 16 -> 4 byte slice
+	ip, ipnet = applyVersionPolicy(ip, ipnet, policy)
+	return ipnet, newResult(ip, ipnet), nil
+}
+
+func calc(cidr string) (Result, error) {
+	_, r, err := Parse(cidr)
+	return r, err
+}
+
+// newResult describes ip in ipnet, taking the IP version from ipnet so an
+// IPv4-mapped address in an IPv6 network stays IPv6.
+func newResult(ip net.IP, ipnet *net.IPNet) Result {
+	if len(ipnet.IP) == net.IPv4len {
+		ip = ip.To4() // 16 -> 4 byte slice
 	}
 
 	netMask := ipnet.Mask
@@ -96,6 +1026,30 @@ func calc(cidr string) (Result, error) {
 	hostMaskSize := netMaskBits - netMaskSize
 
 	tags := []string{}
+	if len(ip) == net.IPv6len && ip.To4() != nil {
+		// The net.IP methods and IPv4 ranges would see the IPv4 address.
+		tags = append(tags, "IPv4-mapped (RFC 4291)")
+	} else {
+		tags = appendIPTags(tags, ip)
+	}
+
+	return Result{
+		IP:           ip,
+		IsV6:         len(ip) == 16,
+		IPBits:       len(ip) * 8,
+		NetMask:      netMask,
+		NetMaskSize:  netMaskSize,
+		HostMask:     hostMask,
+		HostMaskSize: hostMaskSize,
+		Network:      ipnet.IP,
+		Max:          maxIP(ipnet),
+		IPCount:      new(big.Int).Lsh(big.NewInt(1), uint(hostMaskSize)),
+		Tags:         tags,
+	}
+}
+
+// appendIPTags appends the tags describing ip to tags.
+func appendIPTags(tags []string, ip net.IP) []string {
 	if ip.IsLoopback() {
 		tags = append(tags, "loopback")
 	}
@@ -108,8 +1062,8 @@ func calc(cidr string) (Result, error) {
 	if ip.IsInterfaceLocalMulticast() {
 		tags = append(tags, "interface local multicast")
 	}
-	if ip.IsGlobalUnicast() {
-		// tags = append(tags, "global unicast")
+	if ip.IsPrivate() {
+		tags = append(tags, "private")
 	}
 	if ip.IsLinkLocalUnicast() {
 		tags = append(tags, "link local unicast")
@@ -117,20 +1071,177 @@ func calc(cidr string) (Result, error) {
 	if ip.IsUnspecified() {
 		tags = append(tags, "unspecified")
 	}
+	tags = append(tags, rangeTags(ip)...)
+	// net.IP counts private and reserved addresses as global unicast too.
+	global := ip.IsGlobalUnicast() && !ip.IsPrivate()
+	for _, tag := range tags {
+		if tagMatches(tag, []string{"this network", "reserved"}) {
+			global = false
+		}
+	}
+	if global {
+		tags = append(tags, "global unicast")
+	}
+	return tags
+}
 
-	return Result{
-		IP:           ip,
-		IsV6:         len(ip) == 16,
-		IPBits:       len(ip) * 8,
-		NetMask:      netMask,
-		NetMaskSize:  netMaskSize,
-		HostMask:     hostMask,
-		HostMaskSize: hostMaskSize,
-		Network:      ipnet.IP,
-		Max:          maxIP(ipnet),
-		IPCount:      new(big.Int).Lsh(big.NewInt(1), uint(hostMaskSize)),
-		Tags:         tags,
-	}, nil
+func assertIPCount(r Result, expected string) error {
+	n, ok := new(big.Int).SetString(expected, 10)
+	if !ok {
+		return fmt.Errorf("invalid IP count %q", expected)
+	}
+	if r.IPCount.Cmp(n) != 0 {
+		return fmt.Errorf("IP count mismatch: expected %d, got %d (2 ^ %d)", n, r.IPCount, r.HostMaskSize)
+	}
+	return nil
+}
+
+// canonical returns cidr with its host bits cleared, in the standard form
+// e.g. 2001:DB8::1/32 becomes 2001:db8::/32.
+func canonical(cidr string) (string, error) {
+	_, ipnet, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return ipnet.String(), nil
+}
+
+// netmaskIntCIDR returns the CIDR for the IPv4 address ip with the netmask
+// given as an integer e.g. 4294966272 for 255.255.252.0.
+func netmaskIntCIDR(ip string, netmask string) (string, error) {
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil || strings.Contains(ip, ":") {
+		return "", fmt.Errorf("integer netmask needs an IPv4 address, got %q", ip)
+	}
+	n, err := strconv.ParseUint(netmask, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid netmask integer %q", netmask)
+	}
+	mask := net.IPv4Mask(byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	if !isContiguousMask(mask) {
+		return "", fmt.Errorf("netmask %d (%s) is not contiguous", n, net.IP(mask))
+	}
+	ones, _ := mask.Size()
+	return ip + "/" + strconv.Itoa(ones), nil
+}
+
+// wildcardPrefixLen returns the prefix length for an IPv4 ACL wildcard mask,
+// the complement of a netmask e.g. 0.0.3.255 for /22.
+func wildcardPrefixLen(wildcard string) (int, error) {
+	ip := net.ParseIP(wildcard).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("wildcard mask needs to be in IPv4 form like 0.0.3.255, got %q", wildcard)
+	}
+	mask := maskComplement(net.IPMask(ip))
+	if !isContiguousMask(mask) {
+		return 0, fmt.Errorf("wildcard mask %s is not contiguous: it inverts to %s", wildcard, net.IP(mask))
+	}
+	ones, _ := mask.Size()
+	return ones, nil
+}
+
+// isContiguousMask reports whether m is all ones followed by all zeros, the
+// only masks a prefix length can describe.
+func isContiguousMask(m net.IPMask) bool {
+	_, bits := m.Size()
+	return len(m) > 0 && bits == 8*len(m)
+}
+
+// maskSplit explains how the prefix boundary divides the octet it falls in.
+func maskSplit(r Result) string {
+	netBits := r.NetMaskSize % 8
+	if netBits == 0 {
+		return fmt.Sprintf("no octet is split, /%d falls on an octet boundary", r.NetMaskSize)
+	}
+	step := 1 << uint(8-netBits)
+	values := []string{}
+	for v := 0; v < 256; v += step {
+		if len(values) == 3 && v < 256-step {
+			values = append(values, "...")
+			v = 256 - step
+		}
+		values = append(values, strconv.Itoa(v))
+	}
+	return fmt.Sprintf("octet %d is split: %s, %s, so networks step by %d (%s)",
+		r.NetMaskSize/8+1, plural(netBits, "network bit"), plural(8-netBits, "host bit"), step, strings.Join(values, ", "))
+}
+
+// groupDigits separates the thousands of the decimal number s with sep.
+func groupDigits(s string, sep string) string {
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + sep + s[i:]
+	}
+	return s
+}
+
+// ipv4Class returns the class of an IPv4 address from its leading bits, and
+// the default prefix length for it, which is zero for classes D and E.
+func ipv4Class(ip net.IP) (string, int) {
+	switch b := ip.To4()[0]; {
+	case b < 128:
+		return "A", 8
+	case b < 192:
+		return "B", 16
+	case b < 224:
+		return "C", 24
+	case b < 240:
+		return "D", 0
+	}
+	return "E", 0
+}
+
+// classfulMask compares r's prefix to the classful default for its address.
+func classfulMask(r Result) string {
+	class, ones := ipv4Class(r.IP)
+	switch {
+	case ones == 0:
+		return fmt.Sprintf("none, class %s addresses have no default mask", class)
+	case r.NetMaskSize > ones:
+		return fmt.Sprintf("/%d (class %s), so /%d is subnetted", ones, class, r.NetMaskSize)
+	case r.NetMaskSize < ones:
+		return fmt.Sprintf("/%d (class %s), so /%d is supernetted", ones, class, r.NetMaskSize)
+	}
+	return fmt.Sprintf("/%d (class %s), the same as /%d", ones, class, r.NetMaskSize)
+}
+
+// maskPattern describes the mask as its leading ones and trailing zeros.
+func maskPattern(r Result) string {
+	return fmt.Sprintf("%s, %s", plural(r.NetMaskSize, "one"), plural(r.HostMaskSize, "zero"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// addressFraction returns the fraction of all addresses of r's version which
+// r covers.
+func addressFraction(r Result) *big.Rat {
+	all := new(big.Int).Lsh(big.NewInt(1), uint(r.IPBits))
+	return new(big.Rat).SetFrac(r.IPCount, all)
+}
+
+// percent formats f as a percentage to four significant figures.
+func percent(f *big.Rat) string {
+	p, _ := new(big.Rat).Mul(f, big.NewRat(100, 1)).Float64()
+	return strconv.FormatFloat(p, 'g', 4, 64)
+}
+
+// goLiteral returns Go source for ipnet e.g.
+// &net.IPNet{IP: net.IP{10,20,28,0}, Mask: net.CIDRMask(22,32)}
+// IPv6 bytes are written in hex.
+func goLiteral(ipnet *net.IPNet) string {
+	format := "%d"
+	if len(ipnet.IP) == net.IPv6len {
+		format = "%#02x"
+	}
+	b := make([]string, len(ipnet.IP))
+	for i, octet := range ipnet.IP {
+		b[i] = fmt.Sprintf(format, octet)
+	}
+	ones, bits := ipnet.Mask.Size()
+	return fmt.Sprintf("&net.IPNet{IP: net.IP{%s}, Mask: net.CIDRMask(%d,%d)}", strings.Join(b, ","), ones, bits)
 }
 
 func maxIP(network *net.IPNet) net.IP {
@@ -148,6 +1259,97 @@ func bin(ip net.IP) string {
 	return strings.Join(binaryOctets(ip), " ")
 }
 
+// teachLines annotates the binary octets of r's IP address, returning a line
+// saying whether each octet's bits are network or host bits, marking split
+// octets bit by bit with n and h, and a line of the decimal value each part
+// of an octet contributes.
+func teachLines(r Result) (string, string) {
+	var kinds, values []string
+	for i, b := range r.IP {
+		netBits := r.NetMaskSize - 8*i
+		switch {
+		case netBits >= 8:
+			kinds = append(kinds, "network ")
+			values = append(values, fmt.Sprintf("%-8d", b))
+		case netBits <= 0:
+			kinds = append(kinds, "host    ")
+			values = append(values, fmt.Sprintf("%-8d", b))
+		default:
+			mask := byte(0xff << uint(8-netBits))
+			kinds = append(kinds, strings.Repeat("n", netBits)+strings.Repeat("h", 8-netBits))
+			values = append(values, fmt.Sprintf("%-8s", fmt.Sprintf("%d+%d", b&mask, b&^mask)))
+		}
+	}
+	return strings.TrimRight(strings.Join(kinds, " "), " "), strings.TrimRight(strings.Join(values, " "), " ")
+}
+
+// hextetView returns the 16 bit groups of r's IPv6 address, with a | at
+// the network/host boundary, and a line labelling each by its index. A
+// boundary within a hex digit, as nibbleView would call split, is marked
+// by a | either side of that digit, e.g. 85a3 |0|000 for a /50.
+func hextetView(r Result) (string, string) {
+	var groups, labels []string
+	split := r.NetMaskSize%4 != 0
+	for i := 0; i < len(r.IP)/2; i++ {
+		group := fmt.Sprintf("%02x%02x", r.IP[2*i], r.IP[2*i+1])
+		switch digit := r.NetMaskSize/4 - 4*i; {
+		case split && digit >= 0 && digit < 4:
+			group = group[:digit] + "|" + group[digit:digit+1] + "|" + group[digit+1:]
+		case digit > 0 && digit < 4:
+			group = group[:digit] + "|" + group[digit:]
+		case digit == 0 && i > 0:
+			groups, labels = append(groups, "|"), append(labels, " ")
+		}
+		groups = append(groups, group)
+		labels = append(labels, fmt.Sprintf("%-*s", len(group), fmt.Sprintf("[%d]", i)))
+	}
+	return strings.Join(groups, " "), strings.TrimRight(strings.Join(labels, " "), " ")
+}
+
+// byteOrders returns ip's bytes in hex in network byte order, as stored and
+// sent, and reversed as a little-endian host reads them into an integer,
+// each with that integer's value.
+func byteOrders(ip net.IP) (string, string) {
+	reversed := make(net.IP, len(ip))
+	for i, b := range ip {
+		reversed[len(ip)-1-i] = b
+	}
+	format := func(b net.IP) string {
+		hex := make([]string, len(b))
+		for i := range b {
+			hex[i] = fmt.Sprintf("%02x", b[i])
+		}
+		return fmt.Sprintf("%s = %d", strings.Join(hex, " "), ipToInt(b))
+	}
+	return format(ip), format(reversed)
+}
+
+// nibbleView returns r's network mask as hex digits in hextets, a line
+// marking each digit's 4 bits as all network (n), all host (h) or split (s),
+// and a summary of where the prefix boundary falls.
+func nibbleView(r Result) (string, string, string) {
+	var mask, kinds []byte
+	for i := 0; i < 32; i++ {
+		if i > 0 && i%4 == 0 {
+			mask, kinds = append(mask, ' '), append(kinds, ' ')
+		}
+		mask = append(mask, "0123456789abcdef"[r.NetMask[i/2]>>uint(4-4*(i%2))&0xf])
+		switch bits := r.NetMaskSize - 4*i; {
+		case bits >= 4:
+			kinds = append(kinds, 'n')
+		case bits <= 0:
+			kinds = append(kinds, 'h')
+		default:
+			kinds = append(kinds, 's')
+		}
+	}
+	full := r.NetMaskSize / 4
+	if split := r.NetMaskSize % 4; split != 0 {
+		return string(mask), string(kinds), fmt.Sprintf("/%d splits nibble %d into %d network and %d host bits", r.NetMaskSize, full+1, split, 4-split)
+	}
+	return string(mask), string(kinds), fmt.Sprintf("/%d is on a nibble boundary: %d network and %d host nibbles", r.NetMaskSize, full, 32-full)
+}
+
 func binaryOctets(ip net.IP) []string {
 	octets := []string{}
 	for i := 0; i < len(ip); i++ {
@@ -156,6 +1358,106 @@ func binaryOctets(ip net.IP) []string {
 	return octets
 }
 
+// reverseBits returns ip with the order of the bits within each byte reversed.
+func reverseBits(ip net.IP) net.IP {
+	reversed := make(net.IP, len(ip))
+	for i, b := range ip {
+		reversed[i] = bits.Reverse8(b)
+	}
+	return reversed
+}
+
+// writeReverseBits writes ip and its bit reversed form, in binary.
+func writeReverseBits(w io.Writer, ip net.IP) {
+	width := strconv.Itoa(len(ipString(ip)))
+	reversed := reverseBits(ip)
+	if len(ipString(reversed)) > len(ipString(ip)) {
+		width = strconv.Itoa(len(ipString(reversed)))
+	}
+	fmt.Fprintf(w, "    IP address:  %-"+width+"s  %s\n", ipString(ip), strings.Join(binaryOctets(ip), " "))
+	fmt.Fprintf(w, " Bits reversed:  %-"+width+"s  %s\n", ipString(reversed), strings.Join(binaryOctets(reversed), " "))
+}
+
+// bitAt returns bit i of ip, where bit 0 is the most significant as shown by
+// binaryOctets.
+func bitAt(ip net.IP, i int) (uint, error) {
+	if i < 0 || i >= len(ip)*8 {
+		return 0, fmt.Errorf("bit %d out of range for %d bit address", i, len(ip)*8)
+	}
+	return uint(ip[i/8]>>(7-uint(i%8))) & 1, nil
+}
+
+// flipBit returns a copy of ip with bit i toggled, counting as bitAt does.
+func flipBit(ip net.IP, i int) (net.IP, error) {
+	if _, err := bitAt(ip, i); err != nil {
+		return nil, err
+	}
+	flipped := append(net.IP{}, ip...)
+	flipped[i/8] ^= 1 << (7 - uint(i%8))
+	return flipped, nil
+}
+
+// writeFlipBit writes r's IP and the IP with bit i toggled, in binary, and
+// whether that leaves it in r's network.
+func writeFlipBit(w io.Writer, r Result, i int) error {
+	flipped, err := flipBit(r.IP, i)
+	if err != nil {
+		return err
+	}
+	width := strconv.Itoa(len(ipString(r.IP)))
+	if len(ipString(flipped)) > len(ipString(r.IP)) {
+		width = strconv.Itoa(len(ipString(flipped)))
+	}
+	network := &net.IPNet{IP: r.Network, Mask: r.NetMask}
+	fmt.Fprintf(w, "    IP address:  %-"+width+"s  %s\n", ipString(r.IP), bin(r.IP))
+	fmt.Fprintf(w, "   Bit flipped:  %-"+width+"s  %s\n", ipString(flipped), bin(flipped))
+	if network.Contains(flipped) {
+		fmt.Fprintf(w, "       Network:  bit %d is a host bit, so %s is still in %s\n", i, ipString(flipped), netString(network))
+	} else {
+		moved := &net.IPNet{IP: flipped.Mask(r.NetMask), Mask: r.NetMask}
+		fmt.Fprintf(w, "       Network:  bit %d is a network bit, so %s is in %s, not %s\n", i, ipString(flipped), netString(moved), netString(network))
+	}
+	return nil
+}
+
+// writeCompareBinary writes r's IP address in binary above other's, with a
+// line of carets under the bits that differ.
+func writeCompareBinary(w io.Writer, r Result, other string) error {
+	ip, err := parseIP(other)
+	if err != nil {
+		return err
+	}
+	if len(ip) != len(r.IP) {
+		return fmt.Errorf("%s and %s are different IP versions", ipString(r.IP), ipString(ip))
+	}
+	a, b := bin(r.IP), bin(ip)
+	carets := make([]byte, len(a))
+	for i := range carets {
+		carets[i] = ' '
+		if a[i] != b[i] {
+			carets[i] = '^'
+		}
+	}
+	width := strconv.Itoa(len(ipString(r.IP)))
+	if len(ipString(ip)) > len(ipString(r.IP)) {
+		width = strconv.Itoa(len(ipString(ip)))
+	}
+	network := &net.IPNet{IP: r.Network, Mask: r.NetMask}
+	fmt.Fprintf(w, "    IP address:  %-"+width+"s  %s\n", ipString(r.IP), a)
+	fmt.Fprintf(w, "            vs:  %-"+width+"s  %s\n", ipString(ip), b)
+	fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("                 %-"+width+"s  %s", "", carets), " "))
+	switch {
+	case ip.Equal(r.IP):
+		fmt.Fprintf(w, "       Network:  no bits differ, both are in %s\n", netString(network))
+	case network.Contains(ip):
+		fmt.Fprintf(w, "       Network:  only host bits differ, so %s is also in %s\n", ipString(ip), netString(network))
+	default:
+		moved := &net.IPNet{IP: ip.Mask(r.NetMask), Mask: r.NetMask}
+		fmt.Fprintf(w, "       Network:  network bits differ, so %s is in %s, not %s\n", ipString(ip), netString(moved), netString(network))
+	}
+	return nil
+}
+
 func maskLine(n int) string {
 	switch n {
 	case 0:
@@ -173,6 +1475,16 @@ func maskLine(n int) string {
 	}
 }
 
+// plainMaskLine is maskLine bracketed like [-- 10 --] without the dashes, and
+// the same width.
+func plainMaskLine(n int) string {
+	l := strings.Replace(maskLine(n), "-", " ", -1)
+	if strings.HasPrefix(l, "|") {
+		l = "[" + l[1:len(l)-1] + "]"
+	}
+	return l
+}
+
 func maskLineDynamic(n int) string {
 	len := n - (2 * len("|")) - (2 * len(" ")) - len(strconv.Itoa(n)) + ((n - 1) / 8)
 	if len < 0 {
@@ -183,6 +1495,25 @@ func maskLineDynamic(n int) string {
 	return "|" + lineL + " " + strconv.Itoa(n) + " " + lineR + "|"
 }
 
+// formatMask renders m as dotted/colon address form, as a prefix length, or as
+// hex. An inverse (host) mask isn't a prefix, so in prefix form it's shown as
+// the complement of the network prefix e.g. ~/22.
+func formatMask(m net.IPMask, format string, inverse bool) string {
+	switch format {
+	case "prefix":
+		if inverse {
+			ones, _ := maskComplement(m).Size()
+			return fmt.Sprintf("~/%d", ones)
+		}
+		ones, _ := m.Size()
+		return fmt.Sprintf("/%d", ones)
+	case "hex":
+		return "0x" + m.String()
+	default:
+		return net.IP(m).String()
+	}
+}
+
 func maskComplement(m net.IPMask) net.IPMask {
 	comp := make(net.IPMask, len(m))
 	copy(comp, m)
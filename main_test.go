@@ -1,7 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMaskLineLength(t *testing.T) {
@@ -40,3 +48,893 @@ func TestMaskLineLength(t *testing.T) {
 		}
 	}
 }
+
+func TestAssertIPCount(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+		ok       bool
+	}{
+		{"10.0.0.0/24", "256", true},
+		{"10.0.0.0/24", "255", false},
+		{"10.0.0.0/24", "bogus", false},
+		{"2001:db8::/64", "18446744073709551616", true},
+		{"2001:db8::/64", "18446744073709551615", false},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = assertIPCount(r, tt.expected)
+		if tt.ok && err != nil {
+			t.Errorf("%s: expected %s to match, got %v", tt.cidr, tt.expected, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: expected %s to mismatch", tt.cidr, tt.expected)
+		}
+	}
+}
+
+func TestParseArgsInterspersed(t *testing.T) {
+	var o options
+	args, err := parseArgs(newFlagSet(&o), []string{"10.0.0.0/24", "--assert-count", "256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 || args[0] != "10.0.0.0/24" {
+		t.Errorf("expected [10.0.0.0/24], got %v", args)
+	}
+	if o.assertCount != "256" {
+		t.Errorf("expected assert-count 256, got %q", o.assertCount)
+	}
+}
+
+func TestReport(t *testing.T) {
+	expected := `
+          CIDR:  10.20.30.40/20
+          Type:  private
+
+       IP bits:  32 (IPv4)        |-------------- 32 ---------------|
+    IP address:  10.20.30.40      00001010 00010100 00011110 00101000
+
+  Network bits:  20 (..../20)     |-------- 20 --------|
+  Network mask:  255.255.240.0    11111111 11111111 11110000 00000000
+
+     Host bits:  12 (32 - 20)                           |--- 12 ----|
+     Host mask:  0.0.15.255       00000000 00000000 00001111 11111111
+
+ Number of IPs:  4096 (2 ^ 12)
+      First IP:  10.20.16.0       00001010 00010100 00010000 00000000
+       Last IP:  10.20.31.255     00001010 00010100 00011111 11111111
+
+`
+	var buf bytes.Buffer
+	if err := report(&buf, "10.20.30.40/20", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestReportPadFit(t *testing.T) {
+	binaryColumn := func(cidr string, pad string) int {
+		var buf bytes.Buffer
+		if err := report(&buf, cidr, reportOptions{pad: pad}); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasPrefix(line, "    IP address:") {
+				return strings.LastIndex(line, "  ") + 2
+			}
+		}
+		t.Fatalf("no IP address line for %s", cidr)
+		return 0
+	}
+
+	if fixed, fit := binaryColumn("::1/64", "fixed"), binaryColumn("::1/64", "fit"); fit >= fixed {
+		t.Errorf("expected fit column (%d) to be narrower than fixed (%d)", fit, fixed)
+	}
+
+	// the /128 network mask is ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff so
+	// even a compressed ::1 needs the full width.
+	if fixed, fit := binaryColumn("::1/128", "fixed"), binaryColumn("::1/128", "fit"); fit != fixed {
+		t.Errorf("expected fit column (%d) to equal fixed (%d)", fit, fixed)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		outside string
+	}{
+		{"10.20.30.40/22", "10.20.32.0"},
+		{"2001:db8:85a3::8a2e:370:7334/64", "2001:db8:85a3:1::"},
+	}
+	for _, tt := range tests {
+		ipnet, r, err := Parse(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ipnet.IP.Equal(r.Network) {
+			t.Errorf("%s: IPNet.IP %s != Result.Network %s", tt.cidr, ipnet.IP, r.Network)
+		}
+		for _, ip := range []net.IP{r.IP, r.Network, r.Max} {
+			if !ipnet.Contains(ip) {
+				t.Errorf("%s: expected IPNet to contain %s", tt.cidr, ip)
+			}
+		}
+		if ipnet.Contains(net.ParseIP(tt.outside)) {
+			t.Errorf("%s: expected IPNet not to contain %s", tt.cidr, tt.outside)
+		}
+	}
+}
+
+func TestReportMaskFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		netMask  string
+		hostMask string
+	}{
+		{"dotted", "255.255.252.0", "0.0.3.255"},
+		{"prefix", "/22", "~/22"},
+		{"hex", "0xfffffc00", "0x000003ff"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := report(&buf, "10.20.30.40/22", reportOptions{maskFormat: tt.format}); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if line := "  Network mask:  " + tt.netMask + " "; !strings.Contains(out, line) {
+			t.Errorf("%s: expected %q in\n%s", tt.format, line, out)
+		}
+		if line := "     Host mask:  " + tt.hostMask + " "; !strings.Contains(out, line) {
+			t.Errorf("%s: expected %q in\n%s", tt.format, line, out)
+		}
+	}
+}
+
+func TestBitAt(t *testing.T) {
+	ip := net.ParseIP("10.0.0.0").To4() // 00001010 00000000 ...
+	for i, expected := range []uint{0, 0, 0, 0, 1, 0, 1, 0, 0} {
+		bit, err := bitAt(ip, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bit != expected {
+			t.Errorf("bit %d: expected %d, got %d", i, expected, bit)
+		}
+	}
+	for _, i := range []int{-1, 32} {
+		if _, err := bitAt(ip, i); err == nil {
+			t.Errorf("bit %d: expected out of range error", i)
+		}
+	}
+}
+
+func TestReverseBits(t *testing.T) {
+	if got := reverseBits(net.IP{0x80, 0x01, 0xf0, 0xa5}); !got.Equal(net.IP{0x01, 0x80, 0x0f, 0xa5}) {
+		t.Errorf("expected 1.128.15.165, got %s", got)
+	}
+
+	var buf bytes.Buffer
+	writeReverseBits(&buf, net.IP{128, 0, 0, 3})
+	expected := "    IP address:  128.0.0.3  10000000 00000000 00000000 00000011\n" +
+		" Bits reversed:  1.0.0.192  00000001 00000000 00000000 11000000\n"
+	if buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestFlipBit(t *testing.T) {
+	r, _ := calc("10.20.30.40/22")
+	tests := []struct {
+		bit      int
+		flipped  string
+		expected string
+	}{
+		{10, "10.52.30.40", "bit 10 is a network bit, so 10.52.30.40 is in 10.52.28.0/22, not 10.20.28.0/22"},
+		{23, "10.20.31.40", "bit 23 is a host bit, so 10.20.31.40 is still in 10.20.28.0/22"},
+	}
+	for _, tt := range tests {
+		flipped, err := flipBit(r.IP, tt.bit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flipped.String() != tt.flipped {
+			t.Errorf("bit %d: got %s, expected %s", tt.bit, flipped, tt.flipped)
+		}
+		var buf bytes.Buffer
+		if err := writeFlipBit(&buf, r, tt.bit); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "       Network:  "+tt.expected+"\n") {
+			t.Errorf("expected %q in\n%s", tt.expected, buf.String())
+		}
+	}
+	if !r.IP.Equal(net.IP{10, 20, 30, 40}) {
+		t.Errorf("expected the IP to be left unchanged, got %s", r.IP)
+	}
+	if _, err := flipBit(r.IP, 32); err == nil {
+		t.Error("expected bit 32 of an IPv4 address to be rejected")
+	}
+}
+
+func TestNetmaskIntCIDR(t *testing.T) {
+	cidr, err := netmaskIntCIDR("10.0.0.0", "4294966272")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cidr != "10.0.0.0/22" {
+		t.Errorf("expected 10.0.0.0/22, got %s", cidr)
+	}
+	for _, netmask := range []string{"4278255360", "4294967296", "-1", "0xffffff00"} { // 255.0.255.0, 2^32
+		if _, err := netmaskIntCIDR("10.0.0.0", netmask); err == nil {
+			t.Errorf("expected %s to be rejected", netmask)
+		}
+	}
+	for _, ip := range []string{"2001:db8::", "::ffff:10.0.0.0"} {
+		if _, err := netmaskIntCIDR(ip, "4294966272"); err == nil {
+			t.Errorf("expected IPv6 address %s to be rejected", ip)
+		}
+	}
+}
+
+func TestIsContiguousMask(t *testing.T) {
+	tests := []struct {
+		mask     net.IPMask
+		expected bool
+	}{
+		{net.IPv4Mask(255, 255, 252, 0), true},
+		{net.IPv4Mask(255, 255, 255, 255), true},
+		{net.IPv4Mask(0, 0, 0, 0), true},
+		{net.CIDRMask(64, 128), true},
+		{net.IPv4Mask(255, 0, 255, 0), false},
+		{net.IPv4Mask(0, 255, 255, 255), false},
+		{net.IPv4Mask(255, 255, 253, 0), false},
+		{net.IPMask{}, false},
+	}
+	for _, tt := range tests {
+		if got := isContiguousMask(tt.mask); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", net.IP(tt.mask), tt.expected, got)
+		}
+	}
+}
+
+func TestMaskSplit(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.20.30.40/22", "octet 3 is split: 6 network bits, 2 host bits, so networks step by 4 (0, 4, 8, ..., 252)"},
+		{"10.20.30.40/25", "octet 4 is split: 1 network bit, 7 host bits, so networks step by 128 (0, 128)"},
+		{"10.20.30.40/26", "octet 4 is split: 2 network bits, 6 host bits, so networks step by 64 (0, 64, 128, 192)"},
+		{"10.20.30.40/16", "no octet is split, /16 falls on an octet boundary"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := maskSplit(r); got != tt.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tt.cidr, got, tt.expected)
+		}
+	}
+}
+
+func TestMaskPattern(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.20.30.40/22", "22 ones, 10 zeros"},
+		{"10.20.30.40/31", "31 ones, 1 zero"},
+		{"0.0.0.0/0", "0 ones, 32 zeros"},
+		{"2001:db8::/64", "64 ones, 64 zeros"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := maskPattern(r); got != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", got, tt.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, "10.20.30.40/22", reportOptions{maskOnes: true}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "  Mask pattern:  22 ones, 10 zeros\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestClassfulMask(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.5.0.0/22", "/8 (class A), so /22 is subnetted"},
+		{"172.16.0.0/12", "/16 (class B), so /12 is supernetted"},
+		{"192.168.1.0/24", "/24 (class C), the same as /24"},
+		{"224.0.0.0/4", "none, class D addresses have no default mask"},
+		{"240.0.0.1/32", "none, class E addresses have no default mask"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := classfulMask(r); got != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", got, tt.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, "10.5.0.0/22", reportOptions{classful: true}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := " Classful mask:  /8 (class A), so /22 is subnetted\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestReportWildcardBinary(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "10.20.30.40/22", reportOptions{wildcardBinary: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "  ACL wildcard:  0.0.3.255        00000000 00000000 00000011 11111111\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestTeachLines(t *testing.T) {
+	r, _ := calc("10.20.30.40/22")
+	kinds, values := teachLines(r)
+	if expected := "network  network  nnnnnnhh host"; kinds != expected {
+		t.Errorf("\ngot      %s\nexpected %s", kinds, expected)
+	}
+	if expected := "10       20       28+2     40"; values != expected {
+		t.Errorf("\ngot      %s\nexpected %s", values, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, "10.20.30.40/22", reportOptions{teach: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "    IP address:  10.20.30.40      00001010 00010100 00011110 00101000\n" +
+		"                                  network  network  nnnnnnhh host\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestHextetView(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		hextets string
+		labels  string
+	}{
+		{"2001:db8:85a3::8a2e:370:7334/48", "2001 0db8 85a3 | 0000 0000 8a2e 0370 7334", "[0]  [1]  [2]    [3]  [4]  [5]  [6]  [7]"},
+		{"2001:db8::/52", "2001 0db8 0000 0|000 0000 0000 0000 0000", "[0]  [1]  [2]  [3]   [4]  [5]  [6]  [7]"},
+		{"2001:db8:85a3::/50", "2001 0db8 85a3 |0|000 0000 0000 0000 0000", "[0]  [1]  [2]  [3]    [4]  [5]  [6]  [7]"},
+		{"2001:db8::/54", "2001 0db8 0000 0|0|00 0000 0000 0000 0000", "[0]  [1]  [2]  [3]    [4]  [5]  [6]  [7]"},
+		{"2001:db8::/2", "|2|001 0db8 0000 0000 0000 0000 0000 0000", "[0]    [1]  [2]  [3]  [4]  [5]  [6]  [7]"},
+		{"::1/128", "0000 0000 0000 0000 0000 0000 0000 0001", "[0]  [1]  [2]  [3]  [4]  [5]  [6]  [7]"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hextets, labels := hextetView(r)
+		if hextets != tt.hextets || labels != tt.labels {
+			t.Errorf("%s:\ngot      %s\n         %s\nexpected %s\n         %s", tt.cidr, hextets, labels, tt.hextets, tt.labels)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"10.20.30.40/22"}, 0, "          CIDR:  10.20.30.40/22\n", ""},
+		{[]string{"10.20.30.40"}, exitFailure, "", "specify a CIDR"},
+		{[]string{}, exitFailure, "", "specify a CIDR"},
+		{[]string{"--bogus", "10.20.30.40/22"}, exitFailure, "", "flag provided but not defined: -bogus"},
+		{[]string{"10.20.30.40/22", "--assert-count", "1024"}, 0, "", ""},
+		{[]string{"10.20.30.40/22", "--assert-count", "256"}, exitFailure, "", "IP count mismatch"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v: expected exit %d, got %d", tt.args, tt.code, code)
+		}
+		if !strings.Contains(stdout.String(), tt.stdout) || (tt.stdout == "" && stdout.Len() > 0) {
+			t.Errorf("%v: expected stdout containing %q, got %q", tt.args, tt.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) || (tt.stderr == "" && stderr.Len() > 0) {
+			t.Errorf("%v: expected stderr containing %q, got %q", tt.args, tt.stderr, stderr.String())
+		}
+	}
+}
+
+// IPv4-mapped addresses and networks kept as IPv6 are printed in IPv6 form.
+func TestRunStrictV6Output(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--hosts", "::ffff:10.0.0.0/127"}, "::ffff:10.0.0.0\n::ffff:10.0.0.1\n"},
+		{[]string{"--zone-file", "::ffff:10.0.0.0/128"}, "host---ffff-10-0-0-0 AAAA ::ffff:10.0.0.0\n"},
+		{[]string{"--randomize-subnets", "/121", "--seed", "1", "::ffff:10.0.0.0/121"}, "::ffff:10.0.0.0/121\n"},
+		{[]string{"--alloc", "/121", "::ffff:10.0.0.0/120"}, "::ffff:10.0.0.0/121\nFree: ::ffff:10.0.0.128/121\n"},
+		{[]string{"--prefix-density", "::ffff:10.0.0.0/120", "::ffff:10.0.0.0/121"}, "  Largest free:  ::ffff:10.0.0.128/121\n"},
+		{[]string{"--nearest-aggregate", "::ffff:10.0.0.0/121", "::ffff:10.0.1.0/121"}, "::ffff:10.0.0.0/119\n"},
+		{[]string{"--flip-bit", "127", "::ffff:10.0.0.1/120"}, "so ::ffff:10.0.0.0 is still in ::ffff:10.0.0.0/120\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"--version-policy", "strict-v6"}, tt.args...)
+		if code := run(args, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d: %s", args, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.expected) {
+			t.Errorf("%v: expected %q in\n%s", args, tt.expected, stdout.String())
+		}
+	}
+}
+
+func TestRunIPOnlyCanonical(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--ip-only", "10.20.30.40/22"}, "10.20.30.40\n"},
+		{[]string{"--canonical", "10.20.30.40/22"}, "10.20.28.0/22\n"},
+		{[]string{"--ip-only", "2001:DB8:0:0::1/32"}, "2001:db8::1\n"},
+		{[]string{"--canonical", "2001:DB8:0:0::1/32"}, "2001:db8::/32\n"},
+		{[]string{"--version-policy", "strict-v6", "--ip-only", "::ffff:1.2.3.4/128"}, "::ffff:1.2.3.4\n"},
+		{[]string{"--version-policy", "strict-v6", "--canonical", "::ffff:1.2.3.4/128"}, "::ffff:1.2.3.4/128\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d: %s", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%v:\ngot      %q\nexpected %q", tt.args, stdout.String(), tt.expected)
+		}
+	}
+}
+
+func TestRunColor(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	run([]string{"--color=never", "127.0.0.0/8"}, nil, &stdout, &stderr)
+	if strings.Contains(stdout.String(), "\x1b[") {
+		t.Errorf("expected no escape codes with --color=never, got %q", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "          Type:  loopback\n") {
+		t.Errorf("expected loopback tag in\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	run([]string{"--color=always", "0.0.0.0/8"}, nil, &stdout, &stderr)
+	if expected := "          Type:  " + ansiRed + "unspecified" + ansiReset + "\n"; !strings.Contains(stdout.String(), expected) {
+		t.Errorf("expected %q in %q", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	run([]string{"--color=always", "8.8.8.0/24"}, nil, &stdout, &stderr)
+	if expected := "          Type:  " + ansiGreen + "global unicast" + ansiReset + "\n"; !strings.Contains(stdout.String(), expected) {
+		t.Errorf("expected %q in %q", expected, stdout.String())
+	}
+
+	// Tags with a reference are colored by their name.
+	stdout.Reset()
+	run([]string{"--color=always", "240.0.0.1/32"}, nil, &stdout, &stderr)
+	if expected := "          Type:  " + ansiRed + "reserved (RFC 1112)" + ansiReset + "\n"; !strings.Contains(stdout.String(), expected) {
+		t.Errorf("expected %q in %q", expected, stdout.String())
+	}
+}
+
+func TestAddressFraction(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		ratio   string
+		percent string
+	}{
+		{"0.0.0.0/0", "1", "100"},
+		{"128.0.0.0/1", "1/2", "50"},
+		{"10.0.0.0/24", "1/16777216", "5.96e-06"},
+		{"2001:db8::/3", "1/8", "12.5"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := addressFraction(r)
+		if f.RatString() != tt.ratio || percent(f) != tt.percent {
+			t.Errorf("%s: expected %s (%s%%), got %s (%s%%)", tt.cidr, tt.ratio, tt.percent, f.RatString(), percent(f))
+		}
+	}
+}
+
+func TestGoLiteral(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.20.30.40/22", "&net.IPNet{IP: net.IP{10,20,28,0}, Mask: net.CIDRMask(22,32)}"},
+		{"2001:db8::/32", "&net.IPNet{IP: net.IP{0x20,0x01,0x0d,0xb8,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00}, Mask: net.CIDRMask(32,128)}"},
+	}
+	for _, tt := range tests {
+		_, ipnet, _ := net.ParseCIDR(tt.cidr)
+		if got := goLiteral(ipnet); got != tt.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tt.cidr, got, tt.expected)
+		}
+	}
+}
+
+func TestPlainMaskLine(t *testing.T) {
+	expectations := map[int]string{
+		0:  "",
+		1:  "1",
+		2:  "2 ",
+		3:  "[3]",
+		4:  "[4 ]",
+		8:  "[  8   ]",
+		22: "[--------- 22 ---------]",
+		32: "[-------------- 32 ---------------]",
+	}
+	for n, expected := range expectations {
+		expected = strings.Replace(expected, "-", " ", -1)
+		l := plainMaskLine(n)
+		if len(l) != len(maskLine(n)) {
+			t.Errorf("%d: expected width %d, got %d", n, len(maskLine(n)), len(l))
+		}
+		if l != expected {
+			t.Errorf("\ngot      \"%s\"\nexpected \"%s\"", l, expected)
+		}
+	}
+}
+
+func TestReportGroupDigits(t *testing.T) {
+	tests := []struct {
+		ro       reportOptions
+		expected string
+	}{
+		{reportOptions{}, " Number of IPs:  16777216 (2 ^ 24)\n"},
+		{reportOptions{groupDigits: true, digitSeparator: ","}, " Number of IPs:  16,777,216 (2 ^ 24)\n"},
+		{reportOptions{groupDigits: true, digitSeparator: "_"}, " Number of IPs:  16_777_216 (2 ^ 24)\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := report(&buf, "10.0.0.0/8", tt.ro); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.expected) {
+			t.Errorf("expected %q in\n%s", tt.expected, buf.String())
+		}
+	}
+}
+
+func TestReportIPv6Anycast(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "2001:db8::1/64", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Router anycast:  2001:db8::\n    Highest IP:  2001:db8::ffff:ffff:ffff:ffff (all host bits set)\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+
+	for _, cidr := range []string{"10.0.0.0/24", "2001:db8::/127"} {
+		buf.Reset()
+		if err := report(&buf, cidr, reportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "Router anycast") {
+			t.Errorf("expected no anycast line for %s in\n%s", cidr, buf.String())
+		}
+	}
+}
+
+func TestRunOut(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "report.txt")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.20.30.40/22"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := stdout.String()
+
+	stdout.Reset()
+	if code := run([]string{"--out", out, "10.20.30.40/22"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", b, expected)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+
+	code := run([]string{"--out", filepath.Join(dir, "missing", "report.txt"), "10.20.30.40/22"}, nil, &stdout, &stderr)
+	if code != exitOutputFile {
+		t.Errorf("expected exit %d for uncreatable output, got %d", exitOutputFile, code)
+	}
+}
+
+func TestVersionPolicy(t *testing.T) {
+	tests := []struct {
+		policy   string
+		version  int
+		ipBits   int
+		ip       string
+		maskSize int
+		tags     string
+	}{
+		{"mapped-as-v4", 4, 32, "1.2.3.4", 32, "global unicast"},
+		{"strict-v6", 6, 128, "::ffff:1.2.3.4", 128, "IPv4-mapped (RFC 4291)"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--json", "--version-policy", tt.policy, "::ffff:1.2.3.4/128"}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", tt.policy, code, stderr.String())
+		}
+		var got jsonResult
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != tt.version || got.IPBits != tt.ipBits || got.IP != tt.ip || got.NetMaskSize != tt.maskSize || got.HostMaskSize != 0 {
+			t.Errorf("%s: unexpected result %+v", tt.policy, got)
+		}
+		if strings.Join(got.Tags, ", ") != tt.tags {
+			t.Errorf("%s: got tags %q, expected %q", tt.policy, got.Tags, tt.tags)
+		}
+
+		stdout.Reset()
+		if code := run([]string{"--version-policy", tt.policy, "::ffff:1.2.3.4/128"}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", tt.policy, code, stderr.String())
+		}
+		expected := fmt.Sprintf("Host bits:  0 (%d - %d) ", tt.ipBits, tt.ipBits)
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("%s: expected %q in\n%s", tt.policy, expected, stdout.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--version-policy", "v4", "::ffff:1.2.3.4/128"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected an unknown policy to be rejected")
+	}
+}
+
+func TestRunTrim(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.0/24"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.HasSuffix(stdout.String(), "\n\n") {
+		t.Errorf("expected the report to end with a blank line, got %q", stdout.String())
+	}
+	untrimmed := stdout.String()
+
+	stdout.Reset()
+	if code := run([]string{"--trim", "10.0.0.0/24"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if expected := strings.TrimRight(untrimmed, "\n"); stdout.String() != expected {
+		t.Errorf("\ngot\n%q\nexpected\n%q", stdout.String(), expected)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--trim", "--aggregate", "10.0.0.0/25", "10.0.0.128/25"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "10.0.0.0/24" {
+		t.Errorf("expected trimmed list output, got %q", stdout.String())
+	}
+}
+
+func TestCompareBinary(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.1/24", "--vs", "10.0.1.3"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := `    IP address:  10.0.0.1  00001010 00000000 00000000 00000001
+            vs:  10.0.1.3  00001010 00000000 00000001 00000011
+                                                    ^       ^
+       Network:  network bits differ, so 10.0.1.3 is in 10.0.1.0/24, not 10.0.0.0/24
+`
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"10.0.0.1/24", "--vs", "10.0.0.200"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "only host bits differ, so 10.0.0.200 is also in 10.0.0.0/24") {
+		t.Errorf("expected host bits to differ in\n%s", stdout.String())
+	}
+
+	if code := run([]string{"10.0.0.1/24", "--vs", "::1"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected comparing IPv4 with IPv6 to fail")
+	}
+}
+
+func TestNibbleView(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		mask    string
+		kinds   string
+		summary string
+	}{
+		{"2001:db8::/52", "ffff ffff ffff f000 0000 0000 0000 0000", "nnnn nnnn nnnn nhhh hhhh hhhh hhhh hhhh", "/52 is on a nibble boundary: 13 network and 19 host nibbles"},
+		{"2001:db8::/50", "ffff ffff ffff c000 0000 0000 0000 0000", "nnnn nnnn nnnn shhh hhhh hhhh hhhh hhhh", "/50 splits nibble 13 into 2 network and 2 host bits"},
+		{"::/0", "0000 0000 0000 0000 0000 0000 0000 0000", "hhhh hhhh hhhh hhhh hhhh hhhh hhhh hhhh", "/0 is on a nibble boundary: 0 network and 32 host nibbles"},
+	}
+	for _, tt := range tests {
+		r, _ := calc(tt.cidr)
+		mask, kinds, summary := nibbleView(r)
+		if mask != tt.mask || kinds != tt.kinds || summary != tt.summary {
+			t.Errorf("%s:\ngot      %s\n         %s\n         %s\nexpected %s\n         %s\n         %s", tt.cidr, mask, kinds, summary, tt.mask, tt.kinds, tt.summary)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, "10.0.0.0/24", reportOptions{nibbles: true}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Nibbles:") {
+		t.Errorf("expected no nibbles for IPv4, got\n%s", buf.String())
+	}
+}
+
+func TestReportTimestamp(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600)) }
+	var buf bytes.Buffer
+	if err := report(&buf, "10.0.0.0/24", reportOptions{now: clock}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if expected := "     Timestamp:  2024-03-01T08:30:00Z"; lines[1] != expected {
+		t.Errorf("\ngot      %s\nexpected %s", lines[1], expected)
+	}
+
+	var plain bytes.Buffer
+	if err := report(&plain, "10.0.0.0/24", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if untimed := strings.Join(append(lines[:1], lines[2:]...), "\n"); untimed != plain.String() {
+		t.Errorf("expected only the timestamp line to be added, got\n%s", buf.String())
+	}
+}
+
+func TestReportHumanCount(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "2001:db8::/64", reportOptions{humanCount: true, digitSeparator: ","}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := " Number of IPs:  18,446,744,073,709,551,616 (2 ^ 64, ~18.4 quintillion)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.0.0.0/24", ""},
+		{"10.0.0.0/22", "~1.02 thousand"},
+		{"::/0", "~3.4 x 10^38"},
+	}
+	for _, tt := range tests {
+		r, _ := calc(tt.cidr)
+		if got := approximateCount(r.IPCount); got != tt.expected {
+			t.Errorf("%s: got %q, expected %q", tt.cidr, got, tt.expected)
+		}
+	}
+}
+
+func TestWildcardPrefixLen(t *testing.T) {
+	tests := []struct {
+		wildcard string
+		expected int
+	}{
+		{"0.0.3.255", 22},
+		{"0.0.0.0", 32},
+		{"255.255.255.255", 0},
+	}
+	for _, tt := range tests {
+		got, err := wildcardPrefixLen(tt.wildcard)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.wildcard, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: got /%d, expected /%d", tt.wildcard, got, tt.expected)
+		}
+	}
+	for _, wildcard := range []string{"0.3.0.255", "255.0.0.0", "::ff", "x"} {
+		if _, err := wildcardPrefixLen(wildcard); err == nil {
+			t.Errorf("expected %q to be rejected", wildcard)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--from-wildcard", "0.0.3.255"}, nil, &stdout, &stderr); code != 0 || stdout.String() != "/22\n" {
+		t.Errorf("expected /22, got %d %q %q", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestReportWildcardLength(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "10.0.0.0/22", reportOptions{wildcardLen: true}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := " Wildcard bits:  10-bit wildcard for /22 (0.0.3.255)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := report(&buf, "2001:db8::/80", reportOptions{wildcardLen: true}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := " Wildcard bits:  48-bit wildcard for /80 (::ffff:ffff:ffff)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestReportByteOrder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "1.2.3.4/32", reportOptions{byteOrder: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := " Network order:  01 02 03 04 = 16909060\n    Host order:  04 03 02 01 = 67305985 on little-endian hosts\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
+
+func TestReportV6Form(t *testing.T) {
+	tests := []struct {
+		form     string
+		expected string
+	}{
+		{"compressed", "    IP address:  2001:db8::1  "},
+		{"expanded", "    IP address:  2001:0db8:0000:0000:0000:0000:0000:0001  "},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := report(&buf, "2001:db8::1/64", reportOptions{v6Form: tt.form}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.expected) {
+			t.Errorf("%s: expected %q in\n%s", tt.form, tt.expected, buf.String())
+		}
+	}
+
+	// Masks are hex even where they'd look like IPv4-mapped addresses.
+	var buf bytes.Buffer
+	if err := report(&buf, "2001:db8::/80", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "     Host mask:  ::ffff:ffff:ffff  "; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
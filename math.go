@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// cidrMath evaluates a single operation on CIDRs, writing its result:
+//
+//	CIDR + N     the network N addresses on, e.g. 10.0.0.0/24 + 256 is 10.0.1.0/24
+//	CIDR - N     the network N addresses back
+//	CIDR & CIDR  the intersection of the two networks
+//	CIDR - CIDR  the first network excluding the second, as a list of CIDRs,
+//	             which is empty if the second contains the first
+//	/N - /M      the difference between two prefix lengths
+func cidrMath(w io.Writer, expr string) error {
+	fields := strings.Fields(expr)
+	if len(fields) != 3 {
+		return fmt.Errorf("expected an expression like \"CIDR + N\", got %q", expr)
+	}
+	a, op, b := fields[0], fields[1], fields[2]
+
+	if strings.HasPrefix(a, "/") && strings.HasPrefix(b, "/") {
+		if op != "-" {
+			return fmt.Errorf("prefix lengths can only be subtracted, not %q", op)
+		}
+		aLen, err := strconv.Atoi(a[1:])
+		if err != nil {
+			return fmt.Errorf("invalid prefix length %q", a)
+		}
+		bLen, err := strconv.Atoi(b[1:])
+		if err != nil {
+			return fmt.Errorf("invalid prefix length %q", b)
+		}
+		fmt.Fprintln(w, aLen-bLen)
+		return nil
+	}
+
+	_, aNet, err := parseCIDR(a)
+	if err != nil {
+		return err
+	}
+	if n, ok := new(big.Int).SetString(b, 10); ok {
+		switch op {
+		case "+":
+		case "-":
+			n.Neg(n)
+		default:
+			return fmt.Errorf("a CIDR can only be offset with + or -, not %q", op)
+		}
+		moved, err := offsetNet(aNet, n)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, netString(moved))
+		return nil
+	}
+
+	_, bNet, err := parseCIDR(b)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "&":
+		return writeIntersection(w, aNet, bNet)
+	case "-":
+		if len(aNet.IP) != len(bNet.IP) {
+			return fmt.Errorf("%s and %s are different IP versions", aNet, bNet)
+		}
+		if netContains(bNet, aNet) {
+			return nil // nothing is left
+		}
+		for _, n := range exclude(aNet, bNet) {
+			fmt.Fprintln(w, netString(n))
+		}
+	default:
+		return fmt.Errorf("unknown operator %q between CIDRs, expected & or -", op)
+	}
+	return nil
+}
+
+// offsetNet returns n moved by offset addresses, which must keep it on a
+// prefix boundary and within its IP version's address space.
+func offsetNet(n *net.IPNet, offset *big.Int) (*net.IPNet, error) {
+	ones, bits := n.Mask.Size()
+	block := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if new(big.Int).Mod(offset, block).Sign() != 0 {
+		return nil, fmt.Errorf("%s isn't a multiple of the /%d block size %s", offset, ones, block)
+	}
+	start := new(big.Int).Add(ipToInt(n.IP), offset)
+	if start.Sign() < 0 || start.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(bits))) >= 0 {
+		return nil, fmt.Errorf("moving %s by %s leaves the address space", n, offset)
+	}
+	return &net.IPNet{IP: intToIP(start, len(n.IP)), Mask: n.Mask}, nil
+}
+
+// writeIntersection writes the intersection of a and b, or "no overlap".
+func writeIntersection(w io.Writer, a, b *net.IPNet) error {
+	if len(a.IP) != len(b.IP) {
+		return fmt.Errorf("%s and %s are different IP versions", a, b)
+	}
+	if n := intersect(a, b); n != nil {
+		fmt.Fprintln(w, netString(n))
+	} else {
+		fmt.Fprintln(w, "no overlap")
+	}
+	return nil
+}
+
+// intersect returns the addresses in both a and b, which as CIDRs either
+// overlap entirely or not at all, returning nil when they don't overlap.
+func intersect(a, b *net.IPNet) *net.IPNet {
+	switch {
+	case netContains(a, b):
+		return b
+	case netContains(b, a):
+		return a
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCIDRMath(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"10.0.0.0/24 + 256", "10.0.1.0/24\n"},
+		{"10.0.1.0/24 - 256", "10.0.0.0/24\n"},
+		{"2001:db8::/64 + 18446744073709551616", "2001:db8:0:1::/64\n"},
+		{"10.0.0.0/24 & 10.0.0.128/25", "10.0.0.128/25\n"},
+		{"10.0.0.128/25 & 10.0.0.0/24", "10.0.0.128/25\n"},
+		{"10.0.0.0/24 & 10.0.1.0/24", "no overlap\n"},
+		{"10.0.0.0/24 - 10.0.0.0/26", "10.0.0.64/26\n10.0.0.128/25\n"},
+		{"10.0.0.0/24 - 10.0.0.0/16", ""},
+		{"10.0.0.0/24 - 10.0.0.0/24", ""},
+		{"10.0.0.0/24 - 10.0.1.0/24", "10.0.0.0/24\n"},
+		{"/24 - /26", "-2\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(append([]string{"--cidr-math"}, strings.Fields(tt.expr)...), nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", tt.expr, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%s:\ngot\n%s\nexpected\n%s", tt.expr, stdout.String(), tt.expected)
+		}
+	}
+
+	for _, expr := range []string{"10.0.0.0/24 + 1", "255.255.255.0/24 + 256", "10.0.0.0/24 * 2", "10.0.0.0/24 +", "/24 + /26", "10.0.0.0/24 - 2001:db8::/32", "10.0.0.0/24 & 2001:db8::/32"} {
+		var stdout bytes.Buffer
+		if err := cidrMath(&stdout, expr); err == nil {
+			t.Errorf("expected %q to fail, got %s", expr, stdout.String())
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"10.0.0.0/16", "10.0.1.0/24", "10.0.1.0/24\n"},
+		{"10.0.1.0/24", "10.0.0.0/16", "10.0.1.0/24\n"},
+		{"10.0.0.0/16", "10.1.0.0/16", "no overlap\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{tt.a, "--intersect", tt.b}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s & %s: expected exit 0, got %d: %s", tt.a, tt.b, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%s & %s:\ngot      %s\nexpected %s", tt.a, tt.b, stdout.String(), tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.0/8", "--intersect", "2001:db8::/32"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected intersecting IPv4 with IPv6 to fail")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// profiler times the stages of a run for --profile. A nil *profiler does
+// nothing, so callers needn't check whether profiling is enabled.
+type profiler struct {
+	w      io.Writer
+	start  time.Time
+	last   time.Time
+	stages []string
+}
+
+func newProfiler(w io.Writer, enabled bool) *profiler {
+	if !enabled {
+		return nil
+	}
+	now := time.Now()
+	return &profiler{w: w, start: now, last: now}
+}
+
+// mark ends the named stage, which began when the previous one ended.
+func (p *profiler) mark(stage string) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.stages = append(p.stages, fmt.Sprintf("%s %v", stage, now.Sub(p.last)))
+	p.last = now
+}
+
+// done writes the stage timings, and the throughput when lines were read.
+func (p *profiler) done(lines int) {
+	if p == nil {
+		return
+	}
+	fmt.Fprintf(p.w, "profile: %s, total %v\n", strings.Join(p.stages, ", "), p.last.Sub(p.start))
+	if lines > 0 {
+		per1000 := p.last.Sub(p.start) * 1000 / time.Duration(lines)
+		fmt.Fprintf(p.w, "profile: %d lines, %v per 1000 lines\n", lines, per1000)
+	}
+}
+
+// lineCounter counts the lines read through it.
+type lineCounter struct {
+	r     io.Reader
+	lines int
+}
+
+func (c *lineCounter) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.lines += bytes.Count(b[:n], []byte("\n"))
+	return n, err
+}
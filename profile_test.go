@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	var plain, stdout, stderr bytes.Buffer
+	run([]string{"10.20.30.40/22"}, nil, &plain, &stderr)
+	if code := run([]string{"--profile", "10.20.30.40/22"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if stdout.String() != plain.String() {
+		t.Errorf("expected --profile not to change stdout, got\n%s", stdout.String())
+	}
+	duration := `[0-9.]+[nµm]?s`
+	line := regexp.MustCompile(`^profile: parse ` + duration + `, calc ` + duration + `, format ` + duration + `, total ` + duration + "\n$")
+	if !line.MatchString(stderr.String()) {
+		t.Errorf("unexpected profile output %q", stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	run([]string{"--profile", "--aggregate"}, strings.NewReader("10.0.0.0/25\n10.0.0.128/25\n"), &stdout, &stderr)
+	if stdout.String() != "10.0.0.0/24\n" {
+		t.Errorf("expected --profile not to change stdout, got %q", stdout.String())
+	}
+	if !regexp.MustCompile(`\nprofile: 2 lines, ` + duration + " per 1000 lines\n$").MatchString(stderr.String()) {
+		t.Errorf("unexpected profile output %q", stderr.String())
+	}
+}
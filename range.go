@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// parseIP parses an IP address, returning IPv4 addresses in 4 byte form.
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+	}
+	return ip, nil
+}
+
+// countBetween returns how many addresses there are from start to end
+// inclusive.
+func countBetween(start, end string) (*big.Int, error) {
+	first, err := parseIP(start)
+	if err != nil {
+		return nil, err
+	}
+	last, err := parseIP(end)
+	if err != nil {
+		return nil, err
+	}
+	if len(first) != len(last) {
+		return nil, fmt.Errorf("%s and %s are different IP versions", first, last)
+	}
+	count := new(big.Int).Sub(ipToInt(last), ipToInt(first))
+	if count.Sign() < 0 {
+		return nil, fmt.Errorf("%s comes after %s", first, last)
+	}
+	return count.Add(count, big.NewInt(1)), nil
+}
+
+// largestBlockAt returns the largest aligned CIDR starting at ip, with at
+// most maxBits host bits unless maxBits is negative.
+func largestBlockAt(s string, maxBits int) (*net.IPNet, error) {
+	ip, err := parseIP(s)
+	if err != nil {
+		return nil, err
+	}
+	size := 8 * len(ip)
+	hostBits := size
+	if n := ipToInt(ip); n.Sign() != 0 {
+		hostBits = int(n.TrailingZeroBits())
+	}
+	if maxBits >= 0 && hostBits > maxBits {
+		hostBits = maxBits
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(size-hostBits, size)}, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		start, end string
+		expected   string
+	}{
+		{"10.0.0.5", "10.0.0.250", "246\n"},
+		{"10.0.0.5", "10.0.0.5", "1\n"},
+		{"2001:db8::", "2001:db8::1:0", "65537\n"},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "340282366920938463463374607431768211456\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--between", tt.start, tt.end}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s to %s: expected exit 0, got %d: %s", tt.start, tt.end, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", stdout.String(), tt.expected)
+		}
+	}
+
+	for _, pair := range [][2]string{{"10.0.0.250", "10.0.0.5"}, {"10.0.0.5", "2001:db8::"}, {"10.0.0.5", "nope"}} {
+		if _, err := countBetween(pair[0], pair[1]); err == nil {
+			t.Errorf("expected %s to %s to be rejected", pair[0], pair[1])
+		}
+	}
+}
+
+func TestLargestBlockAt(t *testing.T) {
+	tests := []struct {
+		ip       string
+		maxBits  int
+		expected string
+	}{
+		{"10.0.0.64", -1, "10.0.0.64/26"},
+		{"10.0.0.65", -1, "10.0.0.65/32"},
+		{"10.0.0.0", -1, "10.0.0.0/7"},
+		{"10.0.0.0", 8, "10.0.0.0/24"},
+		{"0.0.0.0", -1, "0.0.0.0/0"},
+		{"10.0.0.64", 8, "10.0.0.64/26"},
+		{"2001:db8::", -1, "2001:db8::/29"},
+	}
+	for _, tt := range tests {
+		ipnet, err := largestBlockAt(tt.ip, tt.maxBits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ipnet.String() != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", ipnet, tt.expected)
+		}
+	}
+}
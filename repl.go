@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// repl reads lines from stdin until EOF. A CIDR is reported and becomes the
+// current one, optionally followed by flags e.g. "10.0.0.0/24 --json".
+// Otherwise the line is a command naming a flag, with or without its dashes,
+// applied to the current CIDR e.g. "split /26".
+func repl(stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet(&options{})
+	prompt := isTerminal(stdin)
+	current := ""
+	scanner := bufio.NewScanner(stdin)
+	for {
+		if prompt {
+			fmt.Fprint(stdout, "> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if strings.ContainsAny(args[0], ".:/") {
+			if _, _, err := parseCIDR(args[0]); err != nil {
+				fmt.Fprintln(stderr, err)
+				continue
+			}
+			current = args[0]
+		} else {
+			name := strings.TrimLeft(args[0], "-")
+			if name == "repl" || flags.Lookup(name) == nil {
+				fmt.Fprintf(stderr, "unknown command %q\n", name)
+				continue
+			}
+			if current == "" {
+				fmt.Fprintln(stderr, "specify a CIDR first e.g. 10.20.30.40/22")
+				continue
+			}
+			args = append(append([]string{"--" + name}, args[1:]...), current)
+		}
+		run(args, strings.NewReader(""), stdout, stderr)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	return 0
+}
+
+// isTerminal reports whether f is an *os.File connected to a terminal.
+func isTerminal(f interface{}) bool {
+	file, ok := f.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
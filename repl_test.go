@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	in := strings.Join([]string{
+		"split /26",
+		"10.0.0.0/24",
+		"split /26",
+		"",
+		"bogus",
+		"10.0.0/24",
+		"--bit-at 24",
+		"192.168.0.0/16 --assert-count 1",
+		"repl",
+	}, "\n")
+	var stdout, stderr bytes.Buffer
+	if code := repl(strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Errorf("expected exit 0, got %d", code)
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "          CIDR:  10.0.0.0/24\n") {
+		t.Errorf("expected a report for 10.0.0.0/24 in\n%s", out)
+	}
+	if !strings.HasSuffix(out, "\n10.0.0.0/26\n10.0.0.64/26\n10.0.0.128/26\n10.0.0.192/26\n0\n") {
+		t.Errorf("expected split and bit-at output to follow the report in\n%s", out)
+	}
+
+	expected := `specify a CIDR first e.g. 10.20.30.40/22
+unknown command "bogus"
+invalid CIDR address: 10.0.0/24
+IP count mismatch: expected 1, got 65536 (2 ^ 16)
+unknown command "repl"
+`
+	if stderr.String() != expected {
+		t.Errorf("\ngot stderr\n%s\nexpected\n%s", stderr.String(), expected)
+	}
+}
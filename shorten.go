@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// expandIPv6 formats an IPv6 address without any compression, as all 8 groups
+// of 4 hex digits.
+func expandIPv6(ip net.IP) string {
+	groups := make([]string, net.IPv6len/2)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%02x%02x", ip[2*i], ip[2*i+1])
+	}
+	return strings.Join(groups, ":")
+}
+
+// rfc5952 formats ip in the RFC 5952 canonical form: lowercase hex without
+// leading zeros, with the longest run of two or more zero fields, the first
+// if tied, compressed to ::. IPv4 addresses are returned in dotted form, and
+// IPv4-mapped ones like ::ffff:1.2.3.4 with a dotted tail, as section 5 says.
+func rfc5952(ip net.IP) string {
+	if len(ip) != net.IPv6len {
+		return ip.String()
+	}
+	if ip.To4() != nil {
+		return ipString(ip)
+	}
+	return compressIPv6(ip)
+}
+
+// compressIPv6 is rfc5952 for any IPv6 address, including IPv4-mapped ones
+// or masks which only look like them.
+func compressIPv6(ip net.IP) string {
+	var fields [8]uint16
+	for i := range fields {
+		fields[i] = uint16(ip[2*i])<<8 | uint16(ip[2*i+1])
+	}
+	start, run := -1, 1
+	for i := 0; i < len(fields); {
+		j := i
+		for j < len(fields) && fields[j] == 0 {
+			j++
+		}
+		if j-i > run {
+			start, run = i, j-i
+		}
+		if j == i {
+			j++
+		}
+		i = j
+	}
+
+	hex := func(fs []uint16) string {
+		parts := make([]string, len(fs))
+		for i, f := range fs {
+			parts[i] = fmt.Sprintf("%x", f)
+		}
+		return strings.Join(parts, ":")
+	}
+	if start < 0 {
+		return hex(fields[:])
+	}
+	return hex(fields[:start]) + "::" + hex(fields[start+run:])
+}
+
+// shorten returns cidr with its address in RFC 5952 form, keeping any host
+// bits, and whether cidr was already written that way.
+func shorten(cidr string) (string, bool, error) {
+	ip, ipnet, err := parseCIDR(cidr)
+	if err != nil {
+		return "", false, err
+	}
+	if len(ipnet.IP) == net.IPv4len {
+		ip = ip.To4()
+	}
+	ones, _ := ipnet.Mask.Size()
+	short := fmt.Sprintf("%s/%d", rfc5952(ip), ones)
+	return short, short == cidr, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRFC5952(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"2001:0DB8:0:0:0:0:0:1", "2001:db8::1"},
+		{"2001:db8:0:0:1:0:0:1", "2001:db8::1:0:0:1"},
+		{"2001:db8:0:1:1:1:1:1", "2001:db8:0:1:1:1:1:1"},
+		{"0:0:0:0:0:0:0:0", "::"},
+		{"fe80:0:0:0:0:0:0:0", "fe80::"},
+		{"0:0:0:0:0:0:0:1", "::1"},
+		{"10.0.0.1", "10.0.0.1"},
+		{"::FFFF:1.2.3.4", "::ffff:1.2.3.4"},
+		{"0:0:0:0:0:ffff:102:304", "::ffff:1.2.3.4"},
+	}
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if !strings.Contains(tt.ip, ":") {
+			ip = ip.To4()
+		}
+		if got := rfc5952(ip); got != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", got, tt.expected)
+		}
+		// The report's address lines use ipString.
+		if got := ipString(ip); got != tt.expected {
+			t.Errorf("ipString disagrees for %s: %s", tt.ip, got)
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--shorten", "2001:0DB8:0:0:0:0:0:1/128"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != "2001:db8::1/128\n" {
+		t.Errorf("\ngot      %q\nexpected %q", got, "2001:db8::1/128\n")
+	}
+	if !strings.Contains(stderr.String(), "not in RFC 5952 form") {
+		t.Errorf("expected non-canonical input to be flagged, got %q", stderr.String())
+	}
+
+	for _, cidr := range []string{"2001:db8::1/128", "::ffff:1.2.3.4/128", "10.0.0.1/24"} {
+		stdout.Reset()
+		stderr.Reset()
+		run([]string{"--shorten", cidr}, nil, &stdout, &stderr)
+		if stdout.String() != cidr+"\n" || stderr.Len() > 0 {
+			t.Errorf("expected canonical %s unchanged and not flagged, got %q %q", cidr, stdout.String(), stderr.String())
+		}
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	run([]string{"--shorten", "::FFFF:1.2.3.4/120"}, nil, &stdout, &stderr)
+	if stdout.String() != "::ffff:1.2.3.4/120\n" || !strings.Contains(stderr.String(), "not in RFC 5952 form") {
+		t.Errorf("expected ::ffff:1.2.3.4/120 flagged, got %q %q", stdout.String(), stderr.String())
+	}
+}
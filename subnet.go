@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// delegateListMax is how many delegated prefixes are listed before eliding.
+const delegateListMax = 8
+
+// parsePrefixLen parses a prefix length written as either 24 or /24.
+func parsePrefixLen(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "/"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid prefix length %q", s)
+	}
+	return n, nil
+}
+
+// checkPrefixBoundary checks ipnet can be divided into /newLen subnets,
+// which every operation splitting a network into subnets relies on.
+func checkPrefixBoundary(ipnet *net.IPNet, newLen int) error {
+	ones, bits := ipnet.Mask.Size()
+	switch {
+	case newLen < ones:
+		return fmt.Errorf("cannot divide /%d into /%d: the new prefix must be at least as long as /%d", ones, newLen, ones)
+	case newLen > bits:
+		version := "IPv4"
+		if bits == 8*net.IPv6len {
+			version = "IPv6"
+		}
+		return fmt.Errorf("cannot divide /%d into /%d: %s prefixes are at most /%d", ones, newLen, version, bits)
+	}
+	return nil
+}
+
+// checkSplitPrefix is checkPrefixBoundary for --split, --plan, --delegate
+// and --count-subnets, whose new prefix must be longer than ipnet's rather
+// than the same network again.
+func checkSplitPrefix(ipnet *net.IPNet, newLen int) error {
+	if ones, _ := ipnet.Mask.Size(); newLen <= ones {
+		return fmt.Errorf("cannot divide /%d into /%d: the new prefix must be longer than /%d", ones, newLen, ones)
+	}
+	return checkPrefixBoundary(ipnet, newLen)
+}
+
+// subnetCount returns how many /newLen subnets fit in ipnet.
+func subnetCount(ipnet *net.IPNet, newLen int) (*big.Int, error) {
+	if err := checkPrefixBoundary(ipnet, newLen); err != nil {
+		return nil, err
+	}
+	ones, _ := ipnet.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(newLen-ones)), nil
+}
+
+// subnets returns the first max /newLen subnets of ipnet, in order.
+func subnets(ipnet *net.IPNet, newLen int, max int) []*net.IPNet {
+	ones, bits := ipnet.Mask.Size()
+	mask := net.CIDRMask(newLen, bits)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-newLen))
+	n := ipToInt(ipnet.IP.Mask(ipnet.Mask))
+	list := []*net.IPNet{}
+	for i := 0; i < max && (newLen-ones >= 63 || int64(i) < int64(1)<<uint(newLen-ones)); i++ {
+		list = append(list, &net.IPNet{IP: intToIP(n, len(ipnet.IP)), Mask: mask})
+		n.Add(n, step)
+	}
+	return list
+}
+
+// checkOutputLimit refuses to print count lines when that's more than max,
+// unless max is zero meaning no limit.
+func checkOutputLimit(count *big.Int, max int) error {
+	if max > 0 && count.Cmp(big.NewInt(int64(max))) > 0 {
+		return fmt.Errorf("refusing to print %d lines, more than --max-output %d", count, max)
+	}
+	return nil
+}
+
+func split(w io.Writer, ipnet *net.IPNet, prefix string, maxOutput int) error {
+	newLen, err := parsePrefixLen(prefix)
+	if err != nil {
+		return err
+	}
+	if err := checkSplitPrefix(ipnet, newLen); err != nil {
+		return err
+	}
+	count, err := subnetCount(ipnet, newLen)
+	if err != nil {
+		return err
+	}
+	if err := checkOutputLimit(count, maxOutput); err != nil {
+		return err
+	}
+	max := math.MaxInt32
+	if count.IsInt64() && count.Int64() < int64(max) {
+		max = int(count.Int64())
+	}
+	for _, n := range subnets(ipnet, newLen, max) {
+		fmt.Fprintln(w, netString(n))
+	}
+	return nil
+}
+
+// halve writes the two halves of ipnet.
+func halve(w io.Writer, ipnet *net.IPNet) error {
+	ones, _ := ipnet.Mask.Size()
+	if err := checkPrefixBoundary(ipnet, ones+1); err != nil {
+		return err
+	}
+	for _, n := range subnets(ipnet, ones+1, 2) {
+		fmt.Fprintln(w, netString(n))
+	}
+	return nil
+}
+
+// parents writes each network containing ipnet, from the next shortest
+// prefix up to /0, which is at most 128 lines so isn't limited.
+func parents(w io.Writer, ipnet *net.IPNet) error {
+	ones, bits := ipnet.Mask.Size()
+	for l := ones - 1; l >= 0; l-- {
+		mask := net.CIDRMask(l, bits)
+		fmt.Fprintln(w, netString(&net.IPNet{IP: ipnet.IP.Mask(mask), Mask: mask}))
+	}
+	return nil
+}
+
+// times returns how many times bigger a is than b, as an integer or, when a
+// is smaller, a fraction with its exact decimal value.
+func times(a, b Result) string {
+	ratio := new(big.Rat).SetFrac(a.IPCount, b.IPCount)
+	if ratio.IsInt() {
+		return ratio.Num().String()
+	}
+	// Counts are powers of two, so 1/2^n has exactly n decimal places.
+	return fmt.Sprintf("%s (%s)", ratio.RatString(), ratio.FloatString(b.HostMaskSize-a.HostMaskSize))
+}
+
+// countSubnets writes how many /prefix subnets fit in ipnet.
+func countSubnets(w io.Writer, ipnet *net.IPNet, prefix string) error {
+	newLen, err := parsePrefixLen(prefix)
+	if err != nil {
+		return err
+	}
+	if err := checkSplitPrefix(ipnet, newLen); err != nil {
+		return err
+	}
+	count, err := subnetCount(ipnet, newLen)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, count)
+	return err
+}
+
+// subnetFor returns the /prefix subnet of ipnet containing the IP target.
+func subnetFor(ipnet *net.IPNet, prefix, target string) (*net.IPNet, error) {
+	if target == "" {
+		return nil, fmt.Errorf("--subnet-of needs an IP given with --for")
+	}
+	newLen, err := parsePrefixLen(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrefixBoundary(ipnet, newLen); err != nil {
+		return nil, err
+	}
+	ip, err := parseIP(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(ip) != len(ipnet.IP) || !ipnet.Contains(ip) {
+		return nil, fmt.Errorf("%s is not within %s", ip, ipnet)
+	}
+	_, bits := ipnet.Mask.Size()
+	mask := net.CIDRMask(newLen, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// hosts writes each usable host IP in r.
+func hosts(w io.Writer, r Result, maxOutput int) error {
+	return eachHost(r, maxOutput, func(ip net.IP) {
+		fmt.Fprintln(w, ipString(ip))
+	})
+}
+
+// zoneFile writes a placeholder A or AAAA record for each usable host IP in
+// r, named by hostName.
+func zoneFile(w io.Writer, r Result, template string, maxOutput int) error {
+	rrType := "A"
+	if r.IsV6 {
+		rrType = "AAAA"
+	}
+	return eachHost(r, maxOutput, func(ip net.IP) {
+		fmt.Fprintf(w, "%s %s %s\n", hostName(template, ip), rrType, ipString(ip))
+	})
+}
+
+// hostName replaces {ip} in template with ip's fields joined by -.
+func hostName(template string, ip net.IP) string {
+	name := strings.NewReplacer(".", "-", ":", "-").Replace(ipString(ip))
+	return strings.Replace(template, "{ip}", name, -1)
+}
+
+// eachHost calls fn with each usable host IP in r, refusing if there are more
+// than maxOutput.
+func eachHost(r Result, maxOutput int, fn func(net.IP)) error {
+	first, last, count := usableRange(r)
+	if err := checkOutputLimit(count, maxOutput); err != nil {
+		return err
+	}
+	one := big.NewInt(1)
+	end := ipToInt(last)
+	for n := ipToInt(first); n.Cmp(end) <= 0; n.Add(n, one) {
+		fn(intToIP(n, len(first)))
+	}
+	return nil
+}
+
+func delegate(w io.Writer, ipnet *net.IPNet, prefix string) error {
+	if len(ipnet.IP) != net.IPv6len {
+		return fmt.Errorf("prefix delegation is for IPv6 networks")
+	}
+	newLen, err := parsePrefixLen(prefix)
+	if err != nil {
+		return err
+	}
+	if err := checkSplitPrefix(ipnet, newLen); err != nil {
+		return err
+	}
+	count, err := subnetCount(ipnet, newLen)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d /%d prefixes in %s\n", count, newLen, netString(ipnet))
+	list := subnets(ipnet, newLen, delegateListMax)
+	for _, n := range list {
+		fmt.Fprintln(w, netString(n))
+	}
+	if more := new(big.Int).Sub(count, big.NewInt(int64(len(list)))); more.Sign() > 0 {
+		fmt.Fprintf(w, "... and %d more\n", more)
+	}
+	return nil
+}
+
+// parsePrefixRange parses a range of prefix lengths written as /24-/28 or
+// 24-28, or a single length like /26.
+func parsePrefixRange(s string) (lo, hi int, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	if lo, err = parsePrefixLen(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return lo, lo, nil
+	}
+	if hi, err = parsePrefixLen(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("invalid prefix range %q: /%d is longer than /%d", s, lo, hi)
+	}
+	return lo, hi, nil
+}
+
+// plan writes a table of how ipnet divides into subnets of each prefix
+// length from lo to hi.
+func plan(w io.Writer, ipnet *net.IPNet, lo, hi int, maxOutput int) error {
+	for _, l := range []int{lo, hi} {
+		if err := checkSplitPrefix(ipnet, l); err != nil {
+			return err
+		}
+	}
+	if err := checkOutputLimit(big.NewInt(int64(hi-lo+2)), maxOutput); err != nil {
+		return err
+	}
+	_, bits := ipnet.Mask.Size()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Prefix\tSubnets\tIPs each\tUsable each")
+	for newLen := lo; newLen <= hi; newLen++ {
+		count, err := subnetCount(ipnet, newLen)
+		if err != nil {
+			return err
+		}
+		sub := newResult(ipnet.IP, &net.IPNet{IP: ipnet.IP, Mask: net.CIDRMask(newLen, bits)})
+		_, _, usable := usableRange(sub)
+		fmt.Fprintf(tw, "/%d\t%d\t%d\t%d\n", newLen, count, sub.IPCount, usable)
+	}
+	return tw.Flush()
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip)
+}
+
+// intToIP returns n as an IP address of size bytes.
+func intToIP(n *big.Int, size int) net.IP {
+	ip := make(net.IP, size)
+	b := n.Bytes()
+	if len(b) > size {
+		b = b[len(b)-size:]
+	}
+	copy(ip[size-len(b):], b)
+	return ip
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDelegate(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("2001:db8:abcd::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := delegate(&buf, ipnet, "/56"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if expected := "256 /56 prefixes in 2001:db8:abcd::/48"; lines[0] != expected {
+		t.Errorf("\ngot      %s\nexpected %s", lines[0], expected)
+	}
+	if len(lines) != delegateListMax+2 {
+		t.Fatalf("expected %d listed prefixes, got\n%s", delegateListMax, buf.String())
+	}
+	for i, expected := range []string{"2001:db8:abcd::/56", "2001:db8:abcd:100::/56", "2001:db8:abcd:200::/56"} {
+		if lines[i+1] != expected {
+			t.Errorf("prefix %d: expected %s, got %s", i, expected, lines[i+1])
+		}
+	}
+	if expected := "... and 248 more"; lines[len(lines)-1] != expected {
+		t.Errorf("\ngot      %s\nexpected %s", lines[len(lines)-1], expected)
+	}
+
+	if err := delegate(&buf, ipnet, "/40"); err == nil {
+		t.Error("expected error delegating a shorter prefix")
+	}
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	if err := delegate(&buf, v4, "/16"); err == nil {
+		t.Error("expected error delegating from IPv4")
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	_, ipnet, _ := net.ParseCIDR("::/0")
+	got := []string{}
+	for _, n := range subnets(ipnet, 1, 8) {
+		got = append(got, n.String())
+	}
+	if expected := "::/1 8000::/1"; strings.Join(got, " ") != expected {
+		t.Errorf("\ngot      %v\nexpected %s", got, expected)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/22")
+	lo, hi, err := parsePrefixRange("/24-/26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := plan(&buf, ipnet, lo, hi, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Prefix  Subnets  IPs each  Usable each
+/24     4        256       254
+/25     8        128       126
+/26     16       64        62
+`
+	if buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	if err := plan(&buf, ipnet, 20, 24, 0); err == nil {
+		t.Error("expected error planning prefixes shorter than the network")
+	}
+	for _, s := range []string{"/28-/24", "24-x", ""} {
+		if _, _, err := parsePrefixRange(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestMaxOutput(t *testing.T) {
+	var buf bytes.Buffer
+	_, slash8, _ := net.ParseCIDR("10.0.0.0/8")
+	if err := split(&buf, slash8, "/32", 65536); err == nil {
+		t.Error("expected splitting a /8 into /32s to be refused")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output when refused, got %d bytes", buf.Len())
+	}
+
+	_, slash24, _ := net.ParseCIDR("10.0.0.0/24")
+	if err := split(&buf, slash24, "/26", 65536); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "10.0.0.0/26\n10.0.0.64/26\n10.0.0.128/26\n10.0.0.192/26\n"; buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	r, _ := calc("10.0.0.0/24")
+	if err := hosts(&buf, r, 253); err == nil {
+		t.Error("expected listing 254 hosts to be refused by a limit of 253")
+	}
+}
+
+func TestHosts(t *testing.T) {
+	var buf bytes.Buffer
+	r, _ := calc("10.0.0.0/30")
+	if err := hosts(&buf, r, 0); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "10.0.0.1\n10.0.0.2\n"; buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestZoneFile(t *testing.T) {
+	var buf bytes.Buffer
+	r, _ := calc("10.0.0.4/30")
+	if err := zoneFile(&buf, r, "{ip}.lab", 0); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "10-0-0-5.lab A 10.0.0.5\n10-0-0-6.lab A 10.0.0.6\n"; buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	r, _ = calc("2001:db8::/127")
+	if err := zoneFile(&buf, r, "host-{ip}", 0); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "host-2001-db8-- AAAA 2001:db8::\nhost-2001-db8--1 AAAA 2001:db8::1\n"; buf.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	r, _ = calc("10.0.0.0/16")
+	if err := zoneFile(&buf, r, "host-{ip}", 65533); err == nil {
+		t.Error("expected a /16 to be refused by a limit of 65533")
+	}
+}
+
+func TestParents(t *testing.T) {
+	var buf bytes.Buffer
+	_, ipnet, _ := net.ParseCIDR("10.20.30.0/24")
+	if err := parents(&buf, ipnet); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 24 {
+		t.Fatalf("expected 24 parents, got %d:\n%s", len(lines), buf.String())
+	}
+	for i, expected := range map[int]string{0: "10.20.30.0/23", 7: "10.20.0.0/16", 15: "10.0.0.0/8", 23: "0.0.0.0/0"} {
+		if lines[i] != expected {
+			t.Errorf("line %d: got %s, expected %s", i, lines[i], expected)
+		}
+	}
+}
+
+func TestTimes(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"10.0.0.0/16", "10.0.0.0/24", "256"},
+		{"10.0.0.0/24", "192.168.0.0/24", "1"},
+		{"10.0.0.0/24", "10.0.0.0/16", "1/256 (0.00390625)"},
+		{"2001:db8::/32", "2001:db8::/48", "65536"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{tt.a, "--times", tt.b}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s times %s: expected exit 0, got %d: %s", tt.a, tt.b, code, stderr.String())
+		}
+		if stdout.String() != tt.expected+"\n" {
+			t.Errorf("%s times %s:\ngot      %s\nexpected %s", tt.a, tt.b, stdout.String(), tt.expected)
+		}
+	}
+}
+
+func TestCountSubnets(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		prefix   string
+		expected string
+	}{
+		{"10.0.0.0/16", "/24", "256\n"},
+		{"2001:db8::/32", "/64", "4294967296\n"},
+		{"2001:db8::/32", "/128", "79228162514264337593543950336\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{tt.cidr, "--count-subnets", tt.prefix}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s into %s: expected exit 0, got %d: %s", tt.cidr, tt.prefix, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("\ngot      %s\nexpected %s", stdout.String(), tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.0/16", "--count-subnets", "/8"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected a shorter prefix to fail")
+	}
+	if !strings.Contains(stderr.String(), "cannot divide /16 into /8") {
+		t.Errorf("unexpected error %q", stderr.String())
+	}
+}
+
+func TestCheckPrefixBoundary(t *testing.T) {
+	tests := []struct {
+		cidr   string
+		newLen int
+		err    string
+	}{
+		{"10.0.0.0/24", 24, ""},
+		{"10.0.0.0/24", 26, ""},
+		{"10.0.0.0/24", 32, ""},
+		{"10.0.0.0/24", 20, "cannot divide /24 into /20: the new prefix must be at least as long as /24"},
+		{"10.0.0.0/24", 33, "cannot divide /24 into /33: IPv4 prefixes are at most /32"},
+		{"2001:db8::/32", 128, ""},
+		{"2001:db8::/32", 129, "cannot divide /32 into /129: IPv6 prefixes are at most /128"},
+	}
+	for _, tt := range tests {
+		_, ipnet, _ := net.ParseCIDR(tt.cidr)
+		err := checkPrefixBoundary(ipnet, tt.newLen)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s into /%d: unexpected error %v", tt.cidr, tt.newLen, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s into /%d:\ngot      %v\nexpected %s", tt.cidr, tt.newLen, err, tt.err)
+		}
+	}
+
+	for _, tt := range []struct {
+		cidr   string
+		newLen int
+		err    string
+	}{
+		{"10.0.0.0/24", 26, ""},
+		{"10.0.0.0/24", 24, "cannot divide /24 into /24: the new prefix must be longer than /24"},
+		{"10.0.0.0/24", 20, "cannot divide /24 into /20: the new prefix must be longer than /24"},
+		{"10.0.0.0/24", 33, "cannot divide /24 into /33: IPv4 prefixes are at most /32"},
+	} {
+		_, ipnet, _ := net.ParseCIDR(tt.cidr)
+		err := checkSplitPrefix(ipnet, tt.newLen)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s into /%d: unexpected error %v", tt.cidr, tt.newLen, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s into /%d:\ngot      %v\nexpected %s", tt.cidr, tt.newLen, err, tt.err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"10.0.0.0/24", "--split", "/24"},
+		{"10.0.0.0/24", "--plan", "/24-/26"},
+		{"10.0.0.0/24", "--count-subnets", "/24"},
+		{"2001:db8::/48", "--delegate", "/48"},
+		{"10.0.0.0/24", "--split", "/20"},
+		{"10.0.0.0/24", "--plan", "/24-/33"},
+		{"10.0.0.0/24", "--count-subnets", "/20"},
+		{"2001:db8::/48", "--delegate", "/129"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, nil, &stdout, &stderr); code == 0 {
+			t.Errorf("%v: expected failure", args)
+		}
+		if !strings.Contains(stderr.String(), "cannot divide") {
+			t.Errorf("%v: unexpected error %q", args, stderr.String())
+		}
+	}
+}
+
+func TestSubnetFor(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.0/16", "--subnet-of", "/24", "--for", "10.0.5.37"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if expected := "10.0.5.0/24\n"; stdout.String() != expected {
+		t.Errorf("\ngot      %s\nexpected %s", stdout.String(), expected)
+	}
+
+	_, parent, _ := net.ParseCIDR("10.0.0.0/16")
+	for _, tt := range []struct{ prefix, target string }{
+		{"/24", "10.1.5.37"},
+		{"/24", "::1"},
+		{"/8", "10.0.5.37"},
+		{"/24", ""},
+	} {
+		if _, err := subnetFor(parent, tt.prefix, tt.target); err == nil {
+			t.Errorf("expected %s for %q to fail", tt.prefix, tt.target)
+		}
+	}
+}
+
+func TestHalve(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"10.0.0.0/24", "10.0.0.0/25\n10.0.0.128/25\n"},
+		{"10.0.0.0/31", "10.0.0.0/32\n10.0.0.1/32\n"},
+		{"::/0", "::/1\n8000::/1\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{tt.cidr, "--halve"}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit 0, got %d: %s", tt.cidr, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%s:\ngot\n%s\nexpected\n%s", tt.cidr, stdout.String(), tt.expected)
+		}
+	}
+
+	// IPv4-mapped halves stay in IPv6 form.
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--version-policy", "strict-v6", "--halve", "::ffff:1.2.3.0/120"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if expected := "::ffff:1.2.3.0/121\n::ffff:1.2.3.128/121\n"; stdout.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"10.0.0.1/32", "--halve"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected halving a /32 to fail")
+	}
+	if !strings.Contains(stderr.String(), "IPv4 prefixes are at most /32") {
+		t.Errorf("unexpected error %q", stderr.String())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// listSummary describes a whole list of CIDRs for --summary-json.
+type listSummary struct {
+	Prefixes          int                       `json:"prefixes"`
+	Versions          map[string]int            `json:"versions"`
+	DistinctAddresses map[string]string         `json:"distinctAddresses"`
+	PrefixLengths     map[string]map[string]int `json:"prefixLengths"`
+	Aggregated        []string                  `json:"aggregated"`
+}
+
+func summarize(nets []*net.IPNet) listSummary {
+	s := listSummary{
+		Prefixes:          len(nets),
+		Versions:          map[string]int{"ipv4": 0, "ipv6": 0},
+		DistinctAddresses: map[string]string{},
+		PrefixLengths:     map[string]map[string]int{"ipv4": {}, "ipv6": {}},
+		Aggregated:        []string{},
+	}
+	for _, n := range nets {
+		ones, _ := n.Mask.Size()
+		v := ipVersionKey(n)
+		s.Versions[v]++
+		s.PrefixLengths[v][strconv.Itoa(ones)]++
+	}
+
+	byVersion := map[string][]*net.IPNet{}
+	for _, n := range aggregate(nets) {
+		byVersion[ipVersionKey(n)] = append(byVersion[ipVersionKey(n)], n)
+		s.Aggregated = append(s.Aggregated, netString(n))
+	}
+	for _, v := range []string{"ipv4", "ipv6"} {
+		s.DistinctAddresses[v] = addressCount(byVersion[v]).String()
+	}
+	return s
+}
+
+func ipVersionKey(n *net.IPNet) string {
+	if len(n.IP) == net.IPv6len {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// countByPrefix writes a table of how many of nets there are of each IP
+// version and prefix length, and how many IPs they add up to. Overlapping
+// nets are counted separately, unlike in distinctAddresses.
+func countByPrefix(w io.Writer, nets []*net.IPNet) error {
+	type key struct{ bits, ones int }
+	counts := map[key]int{}
+	totals := map[key]*big.Int{}
+	keys := []key{}
+	for _, n := range nets {
+		ones, bits := n.Mask.Size()
+		k := key{bits, ones}
+		if totals[k] == nil {
+			totals[k] = new(big.Int)
+			keys = append(keys, k)
+		}
+		counts[k]++
+		totals[k].Add(totals[k], new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].bits != keys[j].bits {
+			return keys[i].bits < keys[j].bits
+		}
+		return keys[i].ones < keys[j].ones
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Version\tPrefix\tPrefixes\tIPs")
+	for _, k := range keys {
+		version := "IPv4"
+		if k.bits == 8*net.IPv6len {
+			version = "IPv6"
+		}
+		fmt.Fprintf(tw, "%s\t/%d\t%d\t%s\n", version, k.ones, counts[k], totals[k])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSummaryJSON(t *testing.T) {
+	in := "10.0.0.0/24\n10.0.1.0/24\n10.0.0.0/25\n2001:db8::/48\n"
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--summary-json"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+
+	var s listSummary
+	if err := json.Unmarshal(stdout.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Prefixes != 4 || s.Versions["ipv4"] != 3 || s.Versions["ipv6"] != 1 {
+		t.Errorf("expected 4 prefixes, 3 IPv4 and 1 IPv6, got %+v", s)
+	}
+	if s.PrefixLengths["ipv4"]["24"] != 2 || s.PrefixLengths["ipv4"]["25"] != 1 || s.PrefixLengths["ipv6"]["48"] != 1 {
+		t.Errorf("unexpected prefix length histogram %v", s.PrefixLengths)
+	}
+	if len(s.PrefixLengths["ipv4"]) != 2 || len(s.PrefixLengths["ipv6"]) != 1 {
+		t.Errorf("unexpected prefix length histogram keys %v", s.PrefixLengths)
+	}
+	if s.DistinctAddresses["ipv4"] != "512" || s.DistinctAddresses["ipv6"] != "1208925819614629174706176" {
+		t.Errorf("unexpected distinct addresses %v", s.DistinctAddresses)
+	}
+	if strings.Join(s.Aggregated, " ") != "10.0.0.0/23 2001:db8::/48" {
+		t.Errorf("unexpected aggregate %v", s.Aggregated)
+	}
+
+	// IPv4-mapped prefixes are counted and listed as IPv6.
+	stdout.Reset()
+	if code := run([]string{"--summary-json", "::ffff:10.0.0.0/120"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	s = listSummary{}
+	if err := json.Unmarshal(stdout.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Versions["ipv6"] != 1 || strings.Join(s.Aggregated, " ") != "::ffff:10.0.0.0/120" {
+		t.Errorf("expected one IPv6 prefix ::ffff:10.0.0.0/120, got %+v", s)
+	}
+}
+
+func TestCountByPrefix(t *testing.T) {
+	in := "10.0.0.0/24\n172.16.0.0/16\n10.0.1.0/24\n2001:db8::/48\n"
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--count-by-prefix"}, strings.NewReader(in), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	expected := `Version  Prefix  Prefixes  IPs
+IPv4     /16     1         65536
+IPv4     /24     2         512
+IPv6     /48     1         1208925819614629174706176
+`
+	if stdout.String() != expected {
+		t.Errorf("\ngot\n%s\nexpected\n%s", stdout.String(), expected)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// specialRanges tags addresses in special-purpose ranges which the net.IP
+// Is* methods don't recognize.
+var specialRanges = []struct {
+	net *net.IPNet
+	tag string
+}{
+	{mustParseCIDR("0.0.0.0/8"), "this network (RFC 1122)"},
+	{mustParseCIDR("169.254.0.0/16"), "IPv4 link-local (RFC 3927)"},
+	{mustParseCIDR("240.0.0.0/4"), "reserved (RFC 1112)"},
+	// Unlike IPv4, every IPv6 interface has a link-local address, and using
+	// one needs the interface as a zone since each link has the same prefix.
+	{mustParseCIDR("fe80::/10"), "IPv6 link-local (RFC 4291) needing a zone like fe80::1%eth0"},
+	{mustParseCIDR("2001::/32"), "Teredo (RFC 4380)"},
+	{mustParseCIDR("2002::/16"), "6to4 (RFC 3056)"},
+}
+
+// reservedIIDs are the ranges of reserved IPv6 interface identifiers, the
+// low 64 bits of an address, listed in RFC 5453.
+var reservedIIDs = []struct {
+	first, last uint64
+	name        string
+}{
+	{0, 0, "subnet-router anycast (RFC 4291)"},
+	{0x02005efffe000000, 0x02005efffe005212, "the IANA Ethernet block (RFC 4291)"},
+	{0x02005efffe005213, 0x02005efffe005213, "Proxy Mobile IPv6 (RFC 6543)"},
+	{0x02005efffe005214, 0x02005efffeffffff, "the IANA Ethernet block (RFC 4291)"},
+	{0xfdffffffffffff80, 0xfdffffffffffffff, "subnet anycast (RFC 2526)"},
+}
+
+// reservedIID returns the name of the reserved range the 8 byte interface
+// identifier iid is in, or "".
+func reservedIID(iid []byte) string {
+	n := binary.BigEndian.Uint64(iid)
+	for _, r := range reservedIIDs {
+		if n >= r.first && n <= r.last {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// iidNote describes the interface identifier of the IPv6 address ip, the
+// low 64 bits, saying whether it's reserved.
+func iidNote(ip net.IP) string {
+	iid := ip[8:]
+	hex := expandIPv6(ip)[20:]
+	if reserved := reservedIID(iid); reserved != "" {
+		return hex + ", reserved for " + reserved
+	}
+	if iid[3] == 0xff && iid[4] == 0xfe {
+		return hex + ", not reserved, though the fffe suggests a modified EUI-64 from a MAC address"
+	}
+	return hex + ", not reserved"
+}
+
+var sixToFour = mustParseCIDR("2002::/16")
+
+// embeddedIPv4 returns the IPv4 address embedded in bytes 2-5 of a 6to4
+// address, or nil for any other address.
+func embeddedIPv4(ip net.IP) net.IP {
+	if len(ip) != net.IPv6len || !sixToFour.Contains(ip) {
+		return nil
+	}
+	return net.IPv4(ip[2], ip[3], ip[4], ip[5]).To4()
+}
+
+// rangeTags returns the tags of the specialRanges containing ip. The
+// unspecified address is tagged as such by newResult, so it isn't also
+// tagged by the ranges containing it.
+func rangeTags(ip net.IP) []string {
+	tags := []string{}
+	if ip.IsUnspecified() {
+		return tags
+	}
+	for _, r := range specialRanges {
+		if r.net.Contains(ip) {
+			tags = append(tags, r.tag)
+		}
+	}
+	return tags
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipnet
+}
+
+// hasTag reports whether r has any of tags.
+func hasTag(r Result, tags []string) bool {
+	for _, tag := range r.Tags {
+		if tagMatches(tag, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagName returns tag without its parenthesized reference and any note after
+// it, e.g. "6to4" for "6to4 (RFC 3056)".
+func tagName(tag string) string {
+	if i := strings.Index(tag, " ("); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// tagMatches reports whether tag is any of tags, each matching either the
+// whole tag or its name.
+func tagMatches(tag string, tags []string) bool {
+	name := tagName(tag)
+	for _, t := range tags {
+		if t == tag || t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTags writes the listed CIDRs having any of tags, returning an error if
+// none do.
+func matchTags(w io.Writer, entries []listEntry, tags []string) error {
+	matched := 0
+	for _, e := range entries {
+		r, err := calc(e.cidr)
+		if err != nil {
+			return e.err(err)
+		}
+		if hasTag(r, tags) {
+			fmt.Fprintln(w, e.cidr)
+			matched++
+		}
+	}
+	if matched == 0 {
+		return fmt.Errorf("no CIDRs matched --match-tag %s", strings.Join(tags, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected string
+	}{
+		{"0.1.2.3/32", "this network (RFC 1122)"},
+		{"0.0.0.0/0", "unspecified"},
+		{"0.0.0.0/8", "unspecified"},
+		{"1.2.3.4/32", "global unicast"},
+		{"10.0.0.1/32", "private"},
+		{"240.0.0.1/32", "reserved (RFC 1112)"},
+		{"2001:0:4136:e378::/64", "Teredo (RFC 4380), global unicast"},
+		{"2002:c000:0201::/48", "6to4 (RFC 3056), global unicast"},
+		{"169.254.1.1/16", "link local unicast, IPv4 link-local (RFC 3927)"},
+		{"fe80::1/64", "link local unicast, IPv6 link-local (RFC 4291) needing a zone like fe80::1%eth0"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(r.Tags, ", "); got != tt.expected {
+			t.Errorf("%s: expected tags %q, got %q", tt.cidr, tt.expected, got)
+		}
+	}
+}
+
+func TestReport6to4(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "2002:c000:0201::/48", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "          Type:  6to4 (RFC 3056), global unicast\n Embedded IPv4:  192.0.2.1\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := report(&buf, "2001:db8::/32", reportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Embedded IPv4") {
+		t.Errorf("expected no embedded IPv4 outside 6to4 in\n%s", buf.String())
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	in := "8.8.8.0/24\n10.0.0.0/8 # office\n2002:c000:0201::/48\n"
+	tests := []struct {
+		tags     []string
+		expected string
+	}{
+		{[]string{"private"}, "10.0.0.0/8\n"},
+		{[]string{"6to4"}, "2002:c000:0201::/48\n"},
+		{[]string{"private", "6to4 (RFC 3056)"}, "10.0.0.0/8\n2002:c000:0201::/48\n"},
+	}
+	for _, tt := range tests {
+		var args []string
+		for _, tag := range tt.tags {
+			args = append(args, "--match-tag", tag)
+		}
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(in), &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d: %s", tt.tags, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%v:\ngot      %q\nexpected %q", tt.tags, stdout.String(), tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--match-tag", "private", "8.8.8.0/24"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected no matches to exit non-zero")
+	}
+}
+
+func TestIIDNote(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"2001:db8::fdff:ffff:ffff:ff80", "fdff:ffff:ffff:ff80, reserved for subnet anycast (RFC 2526)"},
+		{"2001:db8::fdff:ffff:ffff:ffff", "fdff:ffff:ffff:ffff, reserved for subnet anycast (RFC 2526)"},
+		{"2001:db8::200:5eff:fe00:5213", "0200:5eff:fe00:5213, reserved for Proxy Mobile IPv6 (RFC 6543)"},
+		{"2001:db8::", "0000:0000:0000:0000, reserved for subnet-router anycast (RFC 4291)"},
+		{"2001:db8::fdff:ffff:ffff:ff7f", "fdff:ffff:ffff:ff7f, not reserved"},
+		{"2001:db8::211:22ff:fe33:4455", "0211:22ff:fe33:4455, not reserved, though the fffe suggests a modified EUI-64 from a MAC address"},
+	}
+	for _, tt := range tests {
+		if got := iidNote(net.ParseIP(tt.ip)); got != tt.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tt.ip, got, tt.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, "2001:db8::fdff:ffff:ffff:ff80/64", reportOptions{reservedIID: true}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "  Interface ID:  fdff:ffff:ffff:ff80, reserved for subnet anycast (RFC 2526)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in\n%s", expected, buf.String())
+	}
+}
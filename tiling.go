@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+)
+
+// randomTiling returns random sized subnets of ipnet, no smaller than
+// /maxLen, which together cover it exactly. The parent is always split once
+// if it can be, then each half is split again with even odds.
+func randomTiling(rng *rand.Rand, ipnet *net.IPNet, maxLen int) []*net.IPNet {
+	var tile func(n *net.IPNet, top bool) []*net.IPNet
+	tile = func(n *net.IPNet, top bool) []*net.IPNet {
+		ones, bits := n.Mask.Size()
+		if ones >= maxLen || (!top && rng.Intn(2) == 0) {
+			return []*net.IPNet{n}
+		}
+		lower := &net.IPNet{IP: n.IP, Mask: net.CIDRMask(ones+1, bits)}
+		upper, _ := upperSibling(lower)
+		return append(tile(lower, false), tile(upper, false)...)
+	}
+	n := &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask}
+	return tile(n, true)
+}
+
+// checkTiling returns an error unless tiles cover parent exactly, without any
+// gaps or overlaps.
+func checkTiling(parent *net.IPNet, tiles []*net.IPNet) error {
+	sorted := append([]*net.IPNet{}, tiles...)
+	sort.Slice(sorted, func(i, j int) bool { return netLess(sorted[i], sorted[j]) })
+	next := ipToInt(parent.IP.Mask(parent.Mask))
+	for _, t := range sorted {
+		if !netContains(parent, t) {
+			return fmt.Errorf("%s is not within %s", t, parent)
+		}
+		if start := ipToInt(t.IP); start.Cmp(next) != 0 {
+			if start.Cmp(next) < 0 {
+				return fmt.Errorf("%s overlaps the subnet before it", t)
+			}
+			return fmt.Errorf("gap before %s", t)
+		}
+		next.Add(next, addressCount([]*net.IPNet{t}))
+	}
+	end := new(big.Int).Add(ipToInt(parent.IP.Mask(parent.Mask)), addressCount([]*net.IPNet{parent}))
+	if next.Cmp(end) != 0 {
+		return fmt.Errorf("gap at the end of %s", parent)
+	}
+	return nil
+}
+
+// randomizeSubnets writes a shuffled random tiling of ipnet with subnets no
+// smaller than /prefix. A negative seed seeds from the clock.
+func randomizeSubnets(w io.Writer, ipnet *net.IPNet, prefix string, seed int64, maxOutput int) error {
+	maxLen, err := parsePrefixLen(prefix)
+	if err != nil {
+		return err
+	}
+	count, err := subnetCount(ipnet, maxLen)
+	if err != nil {
+		return err
+	}
+	if err := checkOutputLimit(count, maxOutput); err != nil {
+		return err
+	}
+	if seed < 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	tiles := randomTiling(rng, ipnet, maxLen)
+	if err := checkTiling(ipnet, tiles); err != nil {
+		return err
+	}
+	rng.Shuffle(len(tiles), func(i, j int) { tiles[i], tiles[j] = tiles[j], tiles[i] })
+	for _, t := range tiles {
+		fmt.Fprintln(w, netString(t))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRandomTiling(t *testing.T) {
+	_, parent, _ := net.ParseCIDR("10.0.0.0/24")
+	for seed := int64(0); seed < 20; seed++ {
+		tiles := randomTiling(rand.New(rand.NewSource(seed)), parent, 28)
+		if err := checkTiling(parent, tiles); err != nil {
+			t.Errorf("seed %d: %v in %v", seed, err, tiles)
+		}
+		if len(tiles) < 2 {
+			t.Errorf("seed %d: expected the parent to be split, got %v", seed, tiles)
+		}
+		for _, n := range tiles {
+			if ones, _ := n.Mask.Size(); ones > 28 {
+				t.Errorf("seed %d: %s is smaller than /28", seed, n)
+			}
+		}
+	}
+
+	var a, b, stderr bytes.Buffer
+	args := []string{"10.0.0.0/24", "--randomize-subnets", "/28", "--seed", "42"}
+	run(args, nil, &a, &stderr)
+	run(args, nil, &b, &stderr)
+	if a.Len() == 0 || a.String() != b.String() {
+		t.Errorf("expected the same seed to give the same output, got\n%s\nand\n%s%s", a.String(), b.String(), stderr.String())
+	}
+	nets, err := listNets(nil, strings.NewReader(a.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkTiling(parent, nets); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckTiling(t *testing.T) {
+	_, parent, _ := net.ParseCIDR("10.0.0.0/24")
+	tests := []struct {
+		tiles    string
+		expected string
+	}{
+		{"10.0.0.128/25 10.0.0.0/25", ""},
+		{"10.0.0.0/25", "gap at the end of 10.0.0.0/24"},
+		{"10.0.0.0/25 10.0.0.192/26", "gap before 10.0.0.192/26"},
+		{"10.0.0.0/25 10.0.0.64/26 10.0.0.128/25", "10.0.0.64/26 overlaps the subnet before it"},
+		{"10.0.0.0/24 10.0.1.0/24", "10.0.1.0/24 is not within 10.0.0.0/24"},
+	}
+	for _, tt := range tests {
+		tiles, _ := listNets(strings.Fields(tt.tiles), nil)
+		got := ""
+		if err := checkTiling(parent, tiles); err != nil {
+			got = err.Error()
+		}
+		if got != tt.expected {
+			t.Errorf("%s:\ngot      %s\nexpected %s", tt.tiles, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+)
+
+// usableRange returns the range of IPs assignable to hosts. IPv4 networks
+// lose their network and broadcast addresses, except point-to-point /31s
+// (RFC 3021) and single host /32s. IPv6 has no broadcast so every IP counts.
+func usableRange(r Result) (first, last net.IP, count *big.Int) {
+	if r.IsV6 || r.HostMaskSize <= 1 {
+		return r.Network, r.Max, new(big.Int).Set(r.IPCount)
+	}
+	first = intToIP(new(big.Int).Add(ipToInt(r.Network), big.NewInt(1)), len(r.Network))
+	last = intToIP(new(big.Int).Sub(ipToInt(r.Max), big.NewInt(1)), len(r.Max))
+	return first, last, new(big.Int).Sub(r.IPCount, big.NewInt(2))
+}
+
+// UsableRange returns the first and last IPs assignable to hosts, following
+// the same rules as usableRange.
+func (r Result) UsableRange() (net.IP, net.IP) {
+	first, last, _ := usableRange(r)
+	return first, last
+}
+
+// gateway returns the first or last usable IP of r, by convention its
+// gateway. Either end of a /31 point-to-point link works, but a /32 has no
+// other address for a gateway.
+func gateway(r Result, end string) (net.IP, error) {
+	if r.HostMaskSize == 0 {
+		return nil, fmt.Errorf("a /%d is a single host with no gateway", r.NetMaskSize)
+	}
+	first, last, _ := usableRange(r)
+	if end == "last" {
+		return last, nil
+	}
+	return first, nil
+}
+
+// reservedRange is usableRange with front IPs reserved from its start and
+// back IPs from its end.
+func reservedRange(r Result, front, back int) (first, last net.IP, count *big.Int, err error) {
+	first, last, count = usableRange(r)
+	if front < 0 || back < 0 {
+		return nil, nil, nil, fmt.Errorf("reservations can't be negative")
+	}
+	reserved := big.NewInt(int64(front) + int64(back))
+	if reserved.Cmp(count) >= 0 {
+		return nil, nil, nil, fmt.Errorf("reserving %d of %d usable IPs leaves none", reserved, count)
+	}
+	first = intToIP(new(big.Int).Add(ipToInt(first), big.NewInt(int64(front))), len(first))
+	last = intToIP(new(big.Int).Sub(ipToInt(last), big.NewInt(int64(back))), len(last))
+	return first, last, count.Sub(count, reserved), nil
+}
+
+// countUsableTotal writes the total number of usable IPs across the listed
+// CIDRs.
+func countUsableTotal(w io.Writer, entries []listEntry) error {
+	total := new(big.Int)
+	for _, e := range entries {
+		r, err := calc(e.cidr)
+		if err != nil {
+			return e.err(err)
+		}
+		_, _, count := usableRange(r)
+		total.Add(total, count)
+	}
+	fmt.Fprintln(w, total)
+	return nil
+}
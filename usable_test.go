@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUsableRange(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		first string
+		last  string
+		count string
+	}{
+		{"10.0.0.0/24", "10.0.0.1", "10.0.0.254", "254"},
+		{"10.0.0.0/30", "10.0.0.1", "10.0.0.2", "2"},
+		{"10.0.0.0/31", "10.0.0.0", "10.0.0.1", "2"},
+		{"10.0.0.7/32", "10.0.0.7", "10.0.0.7", "1"},
+		{"2001:db8::/126", "2001:db8::", "2001:db8::3", "4"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, last, count := usableRange(r)
+		if first.String() != tt.first || last.String() != tt.last || count.String() != tt.count {
+			t.Errorf("%s: got %s - %s (%s), expected %s - %s (%s)", tt.cidr, first, last, count, tt.first, tt.last, tt.count)
+		}
+	}
+}
+
+func TestResultAccessors(t *testing.T) {
+	tests := []struct {
+		cidr                    string
+		first, last             string
+		usableFirst, usableLast string
+	}{
+		{"10.0.0.9/24", "10.0.0.0", "10.0.0.255", "10.0.0.1", "10.0.0.254"},
+		{"10.0.0.9/31", "10.0.0.8", "10.0.0.9", "10.0.0.8", "10.0.0.9"},
+	}
+	for _, tt := range tests {
+		r, err := calc(tt.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.FirstIP().String() != tt.first || r.LastIP().String() != tt.last {
+			t.Errorf("%s: got %s - %s, expected %s - %s", tt.cidr, r.FirstIP(), r.LastIP(), tt.first, tt.last)
+		}
+		first, last := r.UsableRange()
+		if first.String() != tt.usableFirst || last.String() != tt.usableLast {
+			t.Errorf("%s: got usable %s - %s, expected %s - %s", tt.cidr, first, last, tt.usableFirst, tt.usableLast)
+		}
+	}
+}
+
+func TestCountUsableTotal(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []listEntry{{cidr: "10.0.0.0/24"}, {cidr: "192.168.1.0/24"}}
+	if err := countUsableTotal(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "508\n" {
+		t.Errorf("expected 508, got %q", buf.String())
+	}
+}
+
+func TestReservedRange(t *testing.T) {
+	r, err := calc("10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, last, count, err := reservedRange(r, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "10.0.0.2" || last.String() != "10.0.0.254" || count.String() != "253" {
+		t.Errorf("expected 10.0.0.2 - 10.0.0.254 (253), got %s - %s (%s)", first, last, count)
+	}
+
+	if _, _, _, err := reservedRange(r, 200, 54); err == nil {
+		t.Error("expected reserving every usable IP to be an error")
+	}
+	if _, _, _, err := reservedRange(r, -1, 0); err == nil {
+		t.Error("expected a negative reservation to be an error")
+	}
+}
+
+func TestReportReserved(t *testing.T) {
+	var buf bytes.Buffer
+	if err := report(&buf, "10.0.0.0/24", reportOptions{reserveFront: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+    Usable IPs:  253 (1 reserved)
+  First usable:  10.0.0.2         00001010 00000000 00000000 00000010
+   Last usable:  10.0.0.254       00001010 00000000 00000000 11111110
+`
+	if !strings.HasSuffix(buf.String(), expected+"\n") {
+		t.Errorf("expected report to end with\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestGateway(t *testing.T) {
+	tests := []struct {
+		cidr, end string
+		expected  string
+	}{
+		{"10.0.0.0/24", "first", "10.0.0.1"},
+		{"10.0.0.0/24", "last", "10.0.0.254"},
+		{"10.0.0.0/31", "first", "10.0.0.0"},
+		{"10.0.0.0/31", "last", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{tt.cidr, "--gateway", tt.end}, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s %s: expected exit 0, got %d: %s", tt.cidr, tt.end, code, stderr.String())
+		}
+		if stdout.String() != tt.expected+"\n" {
+			t.Errorf("%s %s:\ngot      %s\nexpected %s", tt.cidr, tt.end, stdout.String(), tt.expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"10.0.0.1/32", "--gateway", "first"}, nil, &stdout, &stderr); code == 0 {
+		t.Error("expected a /32 to have no gateway")
+	}
+}